@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DNSBackend reads and changes the DNS configuration through one system
+// facility, such as resolv.conf, networksetup or PowerShell.
+type DNSBackend interface {
+	// Name identifies the backend in the registry, in snapshots and in
+	// the config file.
+	Name() string
+	// Current returns the DNS servers currently in use.
+	Current() ([]string, error)
+	// Apply switches to the servers of provider.
+	Apply(provider DNSProvider) error
+	// Snapshot captures the current configuration so Restore can put it
+	// back exactly.
+	Snapshot() (Snapshot, error)
+	// Restore puts a snapshot back. A snapshot with DHCP set and nothing
+	// else recorded returns to the servers handed out by DHCP.
+	Restore(snap Snapshot) error
+	// Flush makes the system pick up a change, for example by reloading
+	// the service that reads the configuration. Apply and Restore leave
+	// it to their caller, which flushes once afterwards. Resolver caches
+	// are cleared separately by FlushCaches.
+	Flush() error
+	Capabilities() Capabilities
+}
+
+// Capabilities describes what a backend supports, so callers can skip what
+// does not apply.
+type Capabilities struct {
+	// NeedsAdmin is set when changing settings requires root or
+	// Administrator rights.
+	NeedsAdmin bool
+	// DoT is set when Apply honours DNSProvider.TLSName.
+	DoT bool
+	// DNSSEC is set when Apply honours DNSProvider.DNSSEC.
+	DNSSEC bool
+	// Flush is set when Flush does more than nothing.
+	Flush bool
+}
+
+// sandboxedBackend is implemented by backends that change nothing on the
+// system. Their backups, snapshots and lock live in StateDir instead of the
+// system state directory, and no resolver cache is flushed for them.
+type sandboxedBackend interface {
+	StateDir() string
+}
+
+type backendEntry struct {
+	name     string
+	priority int
+	// detect reports whether "auto" may pick the backend on this system.
+	// A nil detect means the backend is only used when named explicitly.
+	detect func() bool
+	create func() (DNSBackend, error)
+}
+
+var (
+	backendRegistry []backendEntry
+	activeBackend   DNSBackend
+)
+
+// registerBackend adds a backend to the registry. "auto" picks the
+// detected backend with the lowest priority.
+func registerBackend(name string, priority int, detect func() bool, create func() (DNSBackend, error)) {
+	backendRegistry = append(backendRegistry, backendEntry{
+		name:     name,
+		priority: priority,
+		detect:   detect,
+		create:   create,
+	})
+	sort.SliceStable(backendRegistry, func(i, j int) bool {
+		return backendRegistry[i].priority < backendRegistry[j].priority
+	})
+}
+
+// BackendNames lists the registered backends in auto-detection order.
+func BackendNames() []string {
+	var names []string
+	for _, entry := range backendRegistry {
+		names = append(names, entry.name)
+	}
+	return names
+}
+
+// SelectBackend creates the named backend, or detects one for "auto" or an
+// empty name.
+func SelectBackend(name string) (DNSBackend, error) {
+	if name == "" || name == "auto" {
+		for _, entry := range backendRegistry {
+			if entry.detect == nil || !entry.detect() {
+				continue
+			}
+			if backend, err := entry.create(); err == nil {
+				return backend, nil
+			}
+		}
+		return nil, fmt.Errorf("no usable DNS backend found")
+	}
+
+	for _, entry := range backendRegistry {
+		if entry.name == name {
+			return entry.create()
+		}
+	}
+
+	return nil, fmt.Errorf("unknown backend %q (available: %s)", name, strings.Join(BackendNames(), ", "))
+}
+
+// UseBackend makes the named backend the one every flow goes through.
+func UseBackend(name string) error {
+	backend, err := SelectBackend(name)
+	if err != nil {
+		return err
+	}
+	activeBackend = backend
+	return nil
+}
+
+// currentBackend returns the backend in use, detecting one on first use.
+func currentBackend() DNSBackend {
+	if activeBackend == nil {
+		backend, err := SelectBackend(config.Backend)
+		if err != nil {
+			backend, err = SelectBackend("auto")
+		}
+		if err != nil {
+			return unavailableBackend{err: err}
+		}
+		activeBackend = backend
+	}
+	return activeBackend
+}
+
+// ApplyProvider switches DNS to provider through the active backend, on
+// the selected interfaces if any. The original configuration is recorded
+// on the first switch and the current one is backed up before every
+// switch. The results are per interface and only returned when interfaces
+// were selected.
+func ApplyProvider(provider DNSProvider) ([]InterfaceResult, error) {
+	var results []InterfaceResult
+	err := withLock(func() error {
+		var err error
+		results, err = applyProvider(provider)
+		return err
+	})
+	return results, err
+}
+
+func applyProvider(provider DNSProvider) ([]InterfaceResult, error) {
+	if provider.Name == "Reset to Default" {
+		return ResetToDefault()
+	}
+
+	if err := validateServers(provider.Servers); err != nil {
+		return nil, err
+	}
+
+	if err := EnsurePristineSnapshot(); err != nil {
+		return nil, fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	if _, err := CreateBackup(provider); err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+
+	return applyServers(currentBackend(), provider)
+}
+
+// unavailableBackend stands in when no backend can be used, so callers get
+// the reason as an error instead of a nil backend.
+type unavailableBackend struct {
+	err error
+}
+
+func (b unavailableBackend) Name() string                { return "unavailable" }
+func (b unavailableBackend) Current() ([]string, error)  { return nil, b.err }
+func (b unavailableBackend) Apply(DNSProvider) error     { return b.err }
+func (b unavailableBackend) Snapshot() (Snapshot, error) { return Snapshot{}, b.err }
+func (b unavailableBackend) Restore(Snapshot) error      { return b.err }
+func (b unavailableBackend) Flush() error                { return nil }
+func (b unavailableBackend) Capabilities() Capabilities  { return Capabilities{} }
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// fakeBackend keeps the DNS configuration in memory. Selecting it with
+// --backend fake runs every flow of the TUI, GUI and CLI on any OS without
+// touching the system; its backups and snapshots go to a temporary
+// directory. It has two interfaces, the first of which is the active one.
+type fakeBackend struct {
+	mu         sync.Mutex
+	interfaces []fakeInterface
+}
+
+type fakeInterface struct {
+	name    string
+	servers []string
+	dhcp    []string
+}
+
+var fake = newFakeBackend()
+
+func init() {
+	registerBackend("fake", 100, nil, func() (DNSBackend, error) {
+		return fake, nil
+	})
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{
+		interfaces: []fakeInterface{
+			{name: "eth0", servers: []string{"192.168.1.1"}, dhcp: []string{"192.168.1.1"}},
+			{name: "wlan0", servers: []string{"192.168.0.1"}, dhcp: []string{"192.168.0.1"}},
+		},
+	}
+}
+
+func (f *fakeBackend) Name() string {
+	return "fake"
+}
+
+func (f *fakeBackend) Current() ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string{}, f.interfaces[0].servers...), nil
+}
+
+func (f *fakeBackend) Apply(provider DNSProvider) error {
+	if len(provider.Servers) == 0 {
+		return fmt.Errorf("provider %s has no servers", provider.Name)
+	}
+	return f.SetInterfaceServers(f.interfaces[0].name, provider.Servers)
+}
+
+func (f *fakeBackend) Snapshot() (Snapshot, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	snap := Snapshot{
+		Backend: "fake",
+		Target:  f.interfaces[0].name,
+		Servers: append([]string{}, f.interfaces[0].servers...),
+	}
+	for _, iface := range f.interfaces {
+		snap.Interfaces = append(snap.Interfaces, InterfaceSnapshot{
+			Name:    iface.name,
+			Servers: append([]string{}, iface.servers...),
+		})
+	}
+	return snap, nil
+}
+
+func (f *fakeBackend) Restore(snap Snapshot) error {
+	if snap.Backend != "fake" {
+		return fmt.Errorf("cannot restore a %s snapshot with the fake backend", snap.Backend)
+	}
+	if len(snap.Interfaces) > 0 {
+		return restoreInterfaces(f, snap.Interfaces)
+	}
+	if snap.DHCP && len(snap.Servers) == 0 {
+		return f.SetInterfaceServers(f.interfaces[0].name, nil)
+	}
+	return f.SetInterfaceServers(f.interfaces[0].name, snap.Servers)
+}
+
+func (f *fakeBackend) Interfaces() ([]Interface, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var interfaces []Interface
+	for i, iface := range f.interfaces {
+		interfaces = append(interfaces, Interface{
+			Name:    iface.name,
+			Servers: append([]string{}, iface.servers...),
+			Active:  i == 0,
+		})
+	}
+	return interfaces, nil
+}
+
+func (f *fakeBackend) SetInterfaceServers(name string, servers []string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := range f.interfaces {
+		if f.interfaces[i].name != name {
+			continue
+		}
+		if len(servers) == 0 {
+			servers = f.interfaces[i].dhcp
+		}
+		f.interfaces[i].servers = append([]string{}, servers...)
+		return nil
+	}
+	return fmt.Errorf("unknown interface %q", name)
+}
+
+func (f *fakeBackend) Flush() error {
+	return nil
+}
+
+// StateDir keeps the state of the fake backend in a temporary directory,
+// so it never touches the real backups.
+func (f *fakeBackend) StateDir() string {
+	return filepath.Join(os.TempDir(), "dns-switcher-fake")
+}
+
+func (f *fakeBackend) Capabilities() Capabilities {
+	return Capabilities{DoT: true, DNSSEC: true}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useFakeBackend makes every flow of the test go through a fresh fake
+// backend, with its state in a temporary directory.
+func useFakeBackend(t *testing.T) *fakeBackend {
+	t.Helper()
+	dir := t.TempDir()
+	for _, env := range []string{"TMPDIR", "TMP", "TEMP"} {
+		t.Setenv(env, dir)
+	}
+
+	savedBackend, savedFake, savedConfig, savedSelection := activeBackend, fake, config, selectedInterfaces
+	t.Cleanup(func() {
+		activeBackend, fake, config, selectedInterfaces = savedBackend, savedFake, savedConfig, savedSelection
+	})
+
+	fake = newFakeBackend()
+	config = defaultConfig()
+	selectedInterfaces = nil
+	if err := UseBackend("fake"); err != nil {
+		t.Fatal(err)
+	}
+	return fake
+}
+
+// fakeServers returns the servers of every interface of f by name.
+func fakeServers(t *testing.T, f *fakeBackend) map[string][]string {
+	t.Helper()
+	interfaces, err := f.Interfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	servers := map[string][]string{}
+	for _, iface := range interfaces {
+		servers[iface.Name] = iface.Servers
+	}
+	return servers
+}
+
+func checkServers(t *testing.T, f *fakeBackend, want map[string][]string) {
+	t.Helper()
+	if got := fakeServers(t, f); !reflect.DeepEqual(got, want) {
+		t.Fatalf("servers are %v, want %v", got, want)
+	}
+}
+
+var (
+	cloudflare = DNSProvider{Name: "Cloudflare", Servers: []string{"1.1.1.1", "1.0.0.1"}}
+	quad9      = DNSProvider{Name: "Quad9", Servers: []string{"9.9.9.9", "149.112.112.112"}}
+	resetEntry = DNSProvider{Name: "Reset to Default"}
+)
+
+func TestApplyProviderFake(t *testing.T) {
+	f := useFakeBackend(t)
+
+	if _, err := ApplyProvider(cloudflare); err != nil {
+		t.Fatal(err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": cloudflare.Servers, "wlan0": {"192.168.0.1"}})
+
+	if _, err := ApplyProvider(quad9); err != nil {
+		t.Fatal(err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": quad9.Servers, "wlan0": {"192.168.0.1"}})
+
+	// The pristine snapshot is the configuration before the first switch.
+	pristine, ok, err := LoadPristineSnapshot("fake")
+	if err != nil || !ok {
+		t.Fatalf("no pristine snapshot: %v", err)
+	}
+	if !reflect.DeepEqual(pristine.Servers, []string{"192.168.1.1"}) {
+		t.Errorf("pristine snapshot has %v, want the original 192.168.1.1", pristine.Servers)
+	}
+
+	// Every switch backs up what it replaces.
+	backups, err := ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want 2", len(backups))
+	}
+	if backups[0].Provider != "Quad9" || !reflect.DeepEqual(backups[0].Servers, cloudflare.Servers) {
+		t.Errorf("newest backup is %s with %v, want Quad9 with %v", backups[0].Provider, backups[0].Servers, cloudflare.Servers)
+	}
+	if backups[1].Provider != "Cloudflare" || !reflect.DeepEqual(backups[1].Servers, []string{"192.168.1.1"}) {
+		t.Errorf("oldest backup is %s with %v, want Cloudflare with 192.168.1.1", backups[1].Provider, backups[1].Servers)
+	}
+}
+
+func TestApplyProviderFakeInvalid(t *testing.T) {
+	f := useFakeBackend(t)
+
+	_, err := ApplyProvider(DNSProvider{Name: "Bad", Servers: []string{"1.1.1.1; rm -rf /"}})
+	if err == nil || !strings.Contains(err.Error(), "invalid DNS server") {
+		t.Fatalf("got %v, want an invalid server error", err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": {"192.168.1.1"}, "wlan0": {"192.168.0.1"}})
+
+	if backups, _ := ListBackups(); len(backups) != 0 {
+		t.Errorf("a rejected switch left %d backups", len(backups))
+	}
+}
+
+func TestApplyProviderFakeInterfaces(t *testing.T) {
+	f := useFakeBackend(t)
+
+	SelectInterfaces([]string{"wlan0"})
+	results, err := ApplyProvider(cloudflare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Interface != "wlan0" || results[0].Err != nil {
+		t.Fatalf("got results %+v, want wlan0 switched", results)
+	}
+	checkServers(t, f, map[string][]string{"eth0": {"192.168.1.1"}, "wlan0": cloudflare.Servers})
+
+	SelectInterfaces([]string{"all"})
+	if results, err = ApplyProvider(quad9); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("got results %+v, want both interfaces", results)
+	}
+	checkServers(t, f, map[string][]string{"eth0": quad9.Servers, "wlan0": quad9.Servers})
+
+	SelectInterfaces([]string{"eth9"})
+	if _, err = ApplyProvider(cloudflare); err == nil || !strings.Contains(err.Error(), `unknown interface "eth9"`) {
+		t.Fatalf("got %v, want an unknown interface error", err)
+	}
+}
+
+func TestResetToDefaultPristine(t *testing.T) {
+	f := useFakeBackend(t)
+
+	// A server set by hand before the first switch is part of the original
+	// configuration, not DHCP.
+	if err := f.SetInterfaceServers("wlan0", []string{"8.8.8.8"}); err != nil {
+		t.Fatal(err)
+	}
+	SelectInterfaces([]string{"all"})
+	if _, err := ApplyProvider(cloudflare); err != nil {
+		t.Fatal(err)
+	}
+	SelectInterfaces(nil)
+
+	if _, err := ApplyProvider(resetEntry); err != nil {
+		t.Fatal(err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": {"192.168.1.1"}, "wlan0": {"8.8.8.8"}})
+
+	if _, err := os.Stat(pristinePath("fake")); !os.IsNotExist(err) {
+		t.Errorf("pristine snapshot still exists after a reset: %v", err)
+	}
+	backups, err := ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) == 0 || backups[0].Provider != "Reset to Default" || !reflect.DeepEqual(backups[0].Servers, cloudflare.Servers) {
+		t.Errorf("the reset was not backed up: %+v", backups)
+	}
+}
+
+func TestResetToDefaultWithoutPristine(t *testing.T) {
+	f := useFakeBackend(t)
+
+	// Servers changed behind our back; nothing was recorded.
+	if err := f.SetInterfaceServers("eth0", quad9.Servers); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.SetInterfaceServers("wlan0", quad9.Servers); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ApplyProvider(resetEntry); err != nil {
+		t.Fatal(err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": {"192.168.1.1"}, "wlan0": quad9.Servers})
+
+	SelectInterfaces([]string{"wlan0"})
+	results, err := ApplyProvider(resetEntry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Interface != "wlan0" {
+		t.Fatalf("got results %+v, want wlan0 reset", results)
+	}
+	checkServers(t, f, map[string][]string{"eth0": {"192.168.1.1"}, "wlan0": {"192.168.0.1"}})
+}
+
+func TestRestoreBackupFake(t *testing.T) {
+	f := useFakeBackend(t)
+
+	if _, err := ApplyProvider(cloudflare); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ApplyProvider(quad9); err != nil {
+		t.Fatal(err)
+	}
+
+	// The latest backup was taken right before switching to Quad9.
+	b, err := FindBackup("latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreBackup(b); err != nil {
+		t.Fatal(err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": cloudflare.Servers, "wlan0": {"192.168.0.1"}})
+
+	// The restore is itself undoable.
+	undo, err := FindBackup("latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if undo.Provider != "Before restore of "+b.ID || !reflect.DeepEqual(undo.Servers, quad9.Servers) {
+		t.Fatalf("undo backup is %s with %v, want the Quad9 servers", undo.Provider, undo.Servers)
+	}
+	if err := RestoreBackup(undo); err != nil {
+		t.Fatal(err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": quad9.Servers, "wlan0": {"192.168.0.1"}})
+}
+
+func TestRestoreBackupUnknownBackend(t *testing.T) {
+	f := useFakeBackend(t)
+
+	err := RestoreBackup(Backup{ID: "x", Snapshot: Snapshot{Backend: "nonexistent", Servers: []string{"1.1.1.1"}}})
+	if err == nil || !strings.Contains(err.Error(), `unknown backend "nonexistent"`) {
+		t.Fatalf("got %v, want an unknown backend error", err)
+	}
+	checkServers(t, f, map[string][]string{"eth0": {"192.168.1.1"}, "wlan0": {"192.168.0.1"}})
+}
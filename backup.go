@@ -0,0 +1,240 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Snapshot is the DNS configuration of one backend at a point in time.
+// Content holds the raw file for file based backends, Link the symlink
+// target when that file was a link and Missing is set when there was no
+// file at all. Servers is always filled so any
+// snapshot can be displayed; DHCP marks servers that were not set by hand.
+// Backends that configure DNS per interface also record every interface in
+// Interfaces.
+type Snapshot struct {
+	Backend    string              `json:"backend"`
+	Target     string              `json:"target"`
+	Servers    []string            `json:"servers"`
+	DHCP       bool                `json:"dhcp,omitempty"`
+	Content    string              `json:"content,omitempty"`
+	Link       string              `json:"link,omitempty"`
+	Missing    bool                `json:"missing,omitempty"`
+	Interfaces []InterfaceSnapshot `json:"interfaces,omitempty"`
+}
+
+// Backup is a snapshot taken right before a switch, plus what triggered it.
+type Backup struct {
+	ID       string    `json:"id"`
+	Created  time.Time `json:"created"`
+	Provider string    `json:"provider"`
+	Snapshot
+
+	path string
+}
+
+const backupIDFormat = "20060102-150405"
+
+func backupDir() string {
+	return filepath.Join(stateDir(), "backups")
+}
+
+// stateDir is where backups and snapshots are kept: the system state
+// directory, or a sandboxed backend's own.
+func stateDir() string {
+	if backend, ok := currentBackend().(sandboxedBackend); ok {
+		return backend.StateDir()
+	}
+	return systemStateDir()
+}
+
+// CreateBackup saves the current DNS configuration to the backup store and
+// prunes old backups according to the configured retention policy.
+func CreateBackup(provider DNSProvider) (Backup, error) {
+	return createBackup(currentBackend(), provider)
+}
+
+// createBackup is CreateBackup for the configuration backend manages.
+func createBackup(backend DNSBackend, provider DNSProvider) (Backup, error) {
+	snap, err := backend.Snapshot()
+	if err != nil {
+		return Backup{}, err
+	}
+
+	if err := os.MkdirAll(backupDir(), 0755); err != nil {
+		return Backup{}, fmt.Errorf("failed to create %s: %w", backupDir(), err)
+	}
+
+	now := time.Now()
+	b := Backup{
+		ID:       now.Format(backupIDFormat),
+		Created:  now,
+		Provider: provider.Name,
+		Snapshot: snap,
+	}
+	for i := 1; ; i++ {
+		b.path = filepath.Join(backupDir(), b.ID+".json")
+		if _, err := os.Stat(b.path); os.IsNotExist(err) {
+			break
+		}
+		b.ID = fmt.Sprintf("%s-%d", now.Format(backupIDFormat), i)
+	}
+
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return Backup{}, fmt.Errorf("failed to encode backup: %w", err)
+	}
+	if err := os.WriteFile(b.path, data, 0644); err != nil {
+		return Backup{}, fmt.Errorf("failed to create backup: %w", err)
+	}
+
+	if _, err := pruneBackups(config.Backups); err != nil {
+		return b, fmt.Errorf("backup saved but pruning failed: %w", err)
+	}
+
+	return b, nil
+}
+
+// ListBackups returns every known backup, newest first.
+func ListBackups() ([]Backup, error) {
+	var backups []Backup
+
+	entries, err := os.ReadDir(backupDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", backupDir(), err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(backupDir(), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var b Backup
+		if err := json.Unmarshal(data, &b); err != nil {
+			continue
+		}
+		b.path = path
+		backups = append(backups, b)
+	}
+
+	// Older versions left copies next to resolv.conf, which only the
+	// resolv.conf backend can put back.
+	if currentBackend().Name() == "resolv.conf" {
+		backups = append(backups, legacyBackups()...)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+
+	return backups, nil
+}
+
+// FindBackup looks up a backup by ID. "latest" selects the newest one.
+func FindBackup(id string) (Backup, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return Backup{}, err
+	}
+	if len(backups) == 0 {
+		return Backup{}, fmt.Errorf("no backups found")
+	}
+	if id == "latest" {
+		return backups[0], nil
+	}
+	for _, b := range backups {
+		if b.ID == id {
+			return b, nil
+		}
+	}
+	return Backup{}, fmt.Errorf("backup %q not found", id)
+}
+
+// RestoreBackup puts a backup back in place through the backend that took
+// it. The configuration being replaced is backed up first so a restore can
+// itself be undone.
+func RestoreBackup(b Backup) error {
+	return withLock(func() error {
+		return restoreBackup(b)
+	})
+}
+
+func restoreBackup(b Backup) error {
+	backend := currentBackend()
+	if backend.Name() != b.Backend {
+		var err error
+		if backend, err = SelectBackend(b.Backend); err != nil {
+			return fmt.Errorf("restore failed: %w", err)
+		}
+	}
+
+	// The undo backup must capture what the restore overwrites, which is
+	// managed by the backend of the backup rather than the active one.
+	if _, err := createBackup(backend, DNSProvider{Name: "Before restore of " + b.ID}); err != nil {
+		return fmt.Errorf("backup failed: %w", err)
+	}
+
+	if err := backend.Restore(b.Snapshot); err != nil {
+		return fmt.Errorf("restore failed: %w", err)
+	}
+
+	if err := backend.Flush(); err != nil {
+		return err
+	}
+	FlushCaches()
+	return nil
+}
+
+// PruneBackups removes backups that fall outside the retention policy and
+// returns the ones that were deleted.
+func PruneBackups(policy BackupPolicy) ([]Backup, error) {
+	var removed []Backup
+	err := withLock(func() error {
+		var err error
+		removed, err = pruneBackups(policy)
+		return err
+	})
+	return removed, err
+}
+
+// pruneBackups only ever deletes backups in the backup store. The legacy
+// copies next to resolv.conf are listed but neither deleted nor counted.
+func pruneBackups(policy BackupPolicy) ([]Backup, error) {
+	backups, err := ListBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	var own []Backup
+	for _, b := range backups {
+		if filepath.Dir(b.path) == backupDir() {
+			own = append(own, b)
+		}
+	}
+
+	var removed []Backup
+	cutoff := time.Now().AddDate(0, 0, -policy.MaxAgeDays)
+
+	for i, b := range own {
+		expired := policy.MaxAgeDays > 0 && b.Created.Before(cutoff)
+		overflow := policy.Keep > 0 && i >= policy.Keep
+		if !expired && !overflow {
+			continue
+		}
+		if err := os.Remove(b.path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", b.path, err)
+		}
+		removed = append(removed, b)
+	}
+
+	return removed, nil
+}
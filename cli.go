@@ -0,0 +1,658 @@
+//go:build !windows
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+func printUsage() {
+	fmt.Println(labelStyle.Render("Usage:"))
+	fmt.Println("  dns-switcher [--backend NAME] [--interface LIST] [--root DIR] [--netns NAME] [--wait] [--yes]")
+	fmt.Println("               [--theme NAME] [--plain] [command]")
+	fmt.Println()
+	fmt.Println("  dns-switcher                       start the interactive UI")
+	fmt.Println("  dns-switcher apply <provider|ips>  switch to a provider by name or to custom servers")
+	fmt.Println("  dns-switcher apply auto            switch to the fastest provider automatic selection may pick")
+	fmt.Println("  dns-switcher interfaces            list interfaces and their DNS servers")
+	fmt.Println("  dns-switcher flush                 flush the DNS caches of the system")
+	fmt.Println("  dns-switcher daemon [provider]     keep DNS on a healthy provider, failing over as needed")
+	fmt.Println("  dns-switcher proxy run [provider]  run the local forwarding proxy")
+	fmt.Println("  dns-switcher proxy use <provider>  switch the upstream of the running proxy")
+	fmt.Println("  dns-switcher split [apply|remove]  list the split DNS rules, apply them, or undo them")
+	fmt.Println("  dns-switcher lookup <domain>       ask every provider for a domain and compare the answers")
+	fmt.Println("  dns-switcher favorites             list the favorite providers")
+	fmt.Println("  dns-switcher favorites add|remove <provider>")
+	fmt.Println("                                     star or unstar a provider")
+	fmt.Println("  dns-switcher favorites auto <mode> let automatic selection pick from all providers,")
+	fmt.Println("                                     only the favorites, or the others")
+	fmt.Println("  dns-switcher backups list          list saved DNS backups")
+	fmt.Println("  dns-switcher backups show <id>     show the contents of a backup")
+	fmt.Println("  dns-switcher backups restore <id>  restore a backup (\"latest\" for the newest)")
+	fmt.Println("  dns-switcher backups prune         delete backups outside the retention policy")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Backends:"))
+	fmt.Println("  --backend NAME one of " + strings.Join(BackendNames(), ", ") + "; \"fake\" changes nothing on the system")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Locking:"))
+	fmt.Println("  --wait         queue behind another running instance instead of failing")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Confirmation:"))
+	fmt.Println("  --yes, -y      switch without showing the planned changes and asking first;")
+	fmt.Println("                 apply only asks when run from a terminal")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Output:"))
+	fmt.Println("  --theme NAME   colors to use: " + strings.Join(ThemeNames(), ", ") + ";")
+	fmt.Println("                 NO_COLOR turns colors off")
+	fmt.Println("  --plain        no colors, box drawing, spinners or graphs, for screen")
+	fmt.Println("                 readers; used when the output is not a terminal")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Daemon:"))
+	fmt.Println("  daemon [--interval S] [--max-failures N] [--max-latency MS] [--recovery N]")
+	fmt.Println("         [--hold S] [--log FILE] [provider]")
+	fmt.Println("                 defaults come from the \"daemon\" section of the config file")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Proxy:"))
+	fmt.Println("  proxy run [--listen LIST] [provider]")
+	fmt.Println("                 listen on LIST (comma separated, default 127.0.0.1:53,[::1]:53);")
+	fmt.Println("                 point the system at it once with: dns-switcher apply 127.0.0.1 ::1")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Lookup:"))
+	fmt.Println("  lookup <domain> [--type TYPE]")
+	fmt.Println("                 TYPE is one of " + strings.Join(lookupTypeNames, ", ") + " (default A);")
+	fmt.Println("                 providers whose answer differs from the majority are marked")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Interfaces:"))
+	fmt.Println("  --interface LIST  change only these interfaces (comma separated), or \"all\";")
+	fmt.Println("                    without it the active interface is changed, or on Linux the")
+	fmt.Println("                    system-wide configuration")
+	fmt.Println()
+	fmt.Println(labelStyle.Render("Targets (Linux):"))
+	fmt.Println("  --root DIR     read and write DIR/etc/resolv.conf, e.g. an image rootfs or chroot")
+	fmt.Println("  --netns NAME   read and write /etc/netns/NAME/resolv.conf and validate inside NAME")
+}
+
+func runCLI(args []string) int {
+	switch args[0] {
+	case "apply":
+		return runApplyCommand(args[1:])
+	case "backups":
+		return runBackupsCommand(args[1:])
+	case "interfaces":
+		return runInterfacesCommand()
+	case "flush":
+		return runFlushCommand()
+	case "daemon":
+		return runDaemonCommand(args[1:])
+	case "proxy":
+		return runProxyCommand(args[1:])
+	case "split":
+		return runSplitCommand(args[1:])
+	case "lookup":
+		return runLookupCommand(args[1:])
+	case "favorites":
+		return runFavoritesCommand(args[1:])
+	case "help", "-h", "--help":
+		printUsage()
+		return 0
+	}
+
+	fmt.Println(errorStyle.Render("Unknown command: " + args[0]))
+	printUsage()
+	return 2
+}
+
+func runApplyCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(errorStyle.Render("Usage: dns-switcher apply <provider|ips>"))
+		return 2
+	}
+
+	provider, ok := FindProvider(strings.Join(args, " "))
+	if len(args) == 1 && strings.EqualFold(args[0], "auto") {
+		fmt.Println(infoStyle.Render("Testing providers..."))
+		fastest, err := AutoSelect()
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		fmt.Println(infoStyle.Render(fmt.Sprintf("Fastest: %s (%dms)", fastest.Name, fastest.Latency)))
+		provider, ok = fastest, true
+	}
+	if !ok || provider.Name == "Add Custom DNS" {
+		servers := parseCustomDNS(strings.Join(args, " "))
+		if err := validateServers(servers); err != nil {
+			fmt.Println(errorStyle.Render("Unknown provider or " + err.Error()))
+			return 2
+		}
+		provider = DNSProvider{Name: "Custom DNS", Servers: servers, Latency: -1}
+	}
+
+	if !requireAdmin() {
+		return 1
+	}
+
+	if !assumeYes && isTerminal(os.Stdin) && !confirmApply(provider) {
+		fmt.Println(infoStyle.Render("Nothing changed"))
+		return 1
+	}
+
+	results, err := ApplyProvider(provider)
+	if err != nil {
+		if len(results) > 0 {
+			printBox("Interfaces", interfaceResultLines(results))
+		}
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		return 1
+	}
+	statusLines := []string{successStyle.Render("Switched to " + provider.Name)}
+	statusLines = append(statusLines, interfaceResultLines(results)...)
+	if err := currentBackend().Flush(); err != nil {
+		statusLines = append(statusLines, errorStyle.Render("Warning: "+err.Error()))
+	}
+	statusLines = append(statusLines, flushResultLines(FlushCaches())...)
+	splitLines, _ := splitResultLines()
+	statusLines = append(statusLines, splitLines...)
+
+	newDNS, _ := currentBackend().Current()
+	for _, dns := range newDNS {
+		statusLines = append(statusLines, infoStyle.Render(dns))
+	}
+	printBox("Update Status", statusLines)
+
+	if provider.Name != "Reset to Default" {
+		if success, err := ValidateDNS(provider.Servers); !success {
+			printBox("DNS Validation", []string{errorStyle.Render("Validation failed: " + err.Error())})
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// confirmApply shows what switching to provider changes and asks whether
+// to go ahead.
+func confirmApply(provider DNSProvider) bool {
+	plan, err := PlanApply(provider)
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		return false
+	}
+	printBox("Switch to "+provider.Name+" ("+plan.Backend+")", planLines(plan))
+
+	fmt.Print("  Apply these changes? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// isTerminal reports whether f is an interactive terminal rather than a
+// pipe or file, so scripts are never prompted.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+func runFlushCommand() int {
+	if !requireAdmin() {
+		return 1
+	}
+
+	results := FlushCaches()
+	lines := flushResultLines(results)
+	if len(lines) == 0 {
+		lines = append(lines, infoStyle.Render("No DNS caches found"))
+	}
+	printBox("Flush", lines)
+
+	for _, result := range results {
+		if result.Err != nil {
+			return 1
+		}
+	}
+	return 0
+}
+
+func runDaemonCommand(args []string) int {
+	cfg := config.Daemon
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	fs.IntVar(&cfg.IntervalSeconds, "interval", cfg.IntervalSeconds, "seconds between health checks")
+	fs.IntVar(&cfg.MaxFailures, "max-failures", cfg.MaxFailures, "failed checks in a row before failing over")
+	fs.IntVar(&cfg.MaxLatencyMs, "max-latency", cfg.MaxLatencyMs, "latency in ms above which a check fails (0 = no limit)")
+	fs.IntVar(&cfg.RecoveryChecks, "recovery", cfg.RecoveryChecks, "passed checks in a row before switching back")
+	fs.IntVar(&cfg.HoldSeconds, "hold", cfg.HoldSeconds, "minimum seconds between a switch and switching back")
+	fs.StringVar(&cfg.LogFile, "log", cfg.LogFile, "append the log to this file instead of stderr")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() > 0 {
+		cfg.Preferred = strings.Join(fs.Args(), " ")
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	if cfg.LogFile != "" {
+		f, err := os.OpenFile(cfg.LogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		defer f.Close()
+		logger.SetOutput(f)
+	}
+
+	daemon, err := NewDaemon(cfg, logger)
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		return 2
+	}
+
+	if !requireAdmin() {
+		return 1
+	}
+
+	// A switch must not fail because someone else is using the switcher;
+	// the daemon queues behind them.
+	waitForLock = true
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := daemon.Run(ctx); err != nil {
+		logger.Printf("error: %v", err)
+		return 1
+	}
+	return 0
+}
+
+func runProxyCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Println(errorStyle.Render("Usage: dns-switcher proxy run [provider] | proxy use <provider>"))
+		return 2
+	}
+
+	switch args[0] {
+	case "run":
+		fs := flag.NewFlagSet("proxy run", flag.ContinueOnError)
+		listen := fs.String("listen", strings.Join(config.Proxy.Listen, ","), "addresses to listen on, comma separated")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		addrs := parseCustomDNS(*listen)
+
+		var provider DNSProvider
+		var err error
+		if fs.NArg() > 0 {
+			provider, err = resolveProvider(strings.Join(fs.Args(), " "))
+		} else if provider, _, err = ProxyUpstream(); os.IsNotExist(err) {
+			err = fmt.Errorf("no upstream set; pass a provider")
+		}
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 2
+		}
+		routes, err := SplitRoutes()
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 2
+		}
+		upstreams := []DNSProvider{provider}
+		for _, route := range routes {
+			upstreams = append(upstreams, route.Provider)
+		}
+		for _, upstream := range upstreams {
+			if forwardsToItself(addrs, upstream) {
+				fmt.Println(errorStyle.Render("Error: the proxy cannot forward to itself"))
+				return 2
+			}
+		}
+
+		if !requireAdmin() {
+			return 1
+		}
+		if fs.NArg() > 0 {
+			if err := SetProxyUpstream(provider); err != nil {
+				fmt.Println(errorStyle.Render("Error: " + err.Error()))
+				return 1
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logger := log.New(os.Stderr, "", log.LstdFlags)
+		logger.Printf("forwarding to %s (%s)", provider.Name, strings.Join(provider.Servers, ", "))
+		for _, route := range routes {
+			logger.Printf("forwarding %s to %s", route.Domain, route.Provider.Name)
+		}
+		if err := RunProxy(ctx, NewProxy(addrs, provider, routes, logger)); err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		return 0
+
+	case "use":
+		if len(args) < 2 {
+			fmt.Println(errorStyle.Render("Usage: dns-switcher proxy use <provider>"))
+			return 2
+		}
+		provider, err := resolveProvider(strings.Join(args[1:], " "))
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 2
+		}
+		if forwardsToItself(config.Proxy.Listen, provider) {
+			fmt.Println(errorStyle.Render("Error: the proxy cannot forward to itself"))
+			return 2
+		}
+		if !requireAdmin() {
+			return 1
+		}
+		if err := SetProxyUpstream(provider); err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		printBox("Proxy", []string{successStyle.Render("Forwarding to " + provider.Name)})
+		return 0
+	}
+
+	fmt.Println(errorStyle.Render("Unknown proxy command: " + args[0]))
+	printUsage()
+	return 2
+}
+
+func runSplitCommand(args []string) int {
+	routes, err := SplitRoutes()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		return 1
+	}
+
+	if len(args) > 0 && args[0] == "apply" {
+		if !requireAdmin() {
+			return 1
+		}
+		results, err := ApplySplitRules()
+		lines := interfaceResultLines(results)
+		if err != nil && len(results) == 0 {
+			lines = append(lines, errorStyle.Render("Error: "+err.Error()))
+		}
+
+		// The backend does not enforce these; they only work if the
+		// system resolves through the local proxy.
+		var proxyRoutes []SplitRoute
+		for _, route := range routes {
+			if !routedByBackend(route) {
+				proxyRoutes = append(proxyRoutes, route)
+			}
+		}
+		proxyErr := SplitProxyStatus(routes)
+		lines = append(lines, splitRouteLines(proxyRoutes, proxyErr)...)
+
+		if len(lines) == 0 {
+			lines = append(lines, infoStyle.Render("No split DNS rules configured"))
+		}
+		printBox("Split DNS", lines)
+		if err != nil || proxyErr != nil {
+			return 1
+		}
+		return 0
+	} else if len(args) > 0 && args[0] == "remove" {
+		if !requireAdmin() {
+			return 1
+		}
+		results, err := RemoveSplitRules()
+		lines := interfaceResultLines(results)
+		if err != nil && len(results) == 0 {
+			lines = append(lines, errorStyle.Render("Error: "+err.Error()))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, infoStyle.Render("No interface has split DNS routing domains"))
+		}
+		printBox("Split DNS", lines)
+		if err != nil {
+			return 1
+		}
+		return 0
+	} else if len(args) > 0 {
+		fmt.Println(errorStyle.Render("Unknown split command: " + args[0]))
+		printUsage()
+		return 2
+	}
+
+	lines := splitRouteLines(routes, SplitProxyStatus(routes))
+	if len(lines) == 0 {
+		lines = append(lines, infoStyle.Render("No split DNS rules configured"))
+	}
+	printBox("Split DNS", lines)
+	return 0
+}
+
+func runFavoritesCommand(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "add", "remove":
+			if len(args) < 2 {
+				fmt.Println(errorStyle.Render("Usage: dns-switcher favorites " + args[0] + " <provider>"))
+				return 2
+			}
+			provider, ok := FindProvider(strings.Join(args[1:], " "))
+			if !ok || !testable(provider) {
+				fmt.Println(errorStyle.Render("Unknown provider: " + strings.Join(args[1:], " ")))
+				return 2
+			}
+			if err := SetFavorite(provider.Name, args[0] == "add"); err != nil {
+				fmt.Println(errorStyle.Render("Error: " + err.Error()))
+				return 1
+			}
+		case "auto":
+			if len(args) != 2 {
+				fmt.Println(errorStyle.Render("Usage: dns-switcher favorites auto " + strings.Join(autoModes, "|")))
+				return 2
+			}
+			if err := SetAutoMode(args[1]); err != nil {
+				fmt.Println(errorStyle.Render("Error: " + err.Error()))
+				return 2
+			}
+		case "list":
+		default:
+			fmt.Println(errorStyle.Render("Unknown favorites command: " + args[0]))
+			printUsage()
+			return 2
+		}
+	}
+
+	var lines []string
+	for _, name := range userState.Favorites {
+		lines = append(lines, symbol("★ ", "* ")+name)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, infoStyle.Render("No favorites; star providers with f in the UI"))
+	}
+	lines = append(lines, "", helpStyle.Render("Automatic selection picks from: "+map[string]string{
+		"all":       "all providers",
+		"favorites": "the favorites",
+		"others":    "all but the favorites",
+	}[autoMode()]))
+	printBox("Favorites", lines)
+	return 0
+}
+
+func runLookupCommand(args []string) int {
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	typeName := fs.String("type", "A", "record type: "+strings.Join(lookupTypeNames, ", "))
+
+	// The domain may come before or after --type.
+	var domains []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return 2
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		domains = append(domains, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(domains) != 1 {
+		fmt.Println(errorStyle.Render("Usage: dns-switcher lookup <domain> [--type " + strings.Join(lookupTypeNames, "|") + "]"))
+		return 2
+	}
+
+	qtype, err := parseLookupType(*typeName)
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		return 2
+	}
+	results, majority, err := LookupAll(domains[0], qtype)
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		return 2
+	}
+
+	lines := lookupLines(results, 0, "")
+	lines = append(lines, "", infoStyle.Render(lookupSummary(results, majority)))
+	printBox("Lookup "+strings.Trim(domains[0], ".")+" "+lookupTypeName(qtype), lines)
+
+	for _, result := range results {
+		if result.Err == nil {
+			return 0
+		}
+	}
+	return 1
+}
+
+func runInterfacesCommand() int {
+	interfaces, err := ListInterfaces()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		return 1
+	}
+
+	var lines []string
+	for _, iface := range interfaces {
+		name := iface.Name
+		if iface.Active {
+			name += " *"
+		}
+		lines = append(lines, fmt.Sprintf("%-24s %s", name, strings.Join(iface.Servers, " ")))
+	}
+	if len(lines) == 0 {
+		lines = append(lines, infoStyle.Render("No interfaces found"))
+	}
+	printBox("Interfaces", lines)
+	return 0
+}
+
+func runBackupsCommand(args []string) int {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		backups, err := ListBackups()
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		if len(backups) == 0 {
+			printBox("Backups", []string{infoStyle.Render("No backups found")})
+			return 0
+		}
+		var lines []string
+		for _, b := range backups {
+			lines = append(lines, formatBackupLine(b))
+		}
+		printBox("Backups", lines)
+		return 0
+
+	case "show":
+		if len(args) < 2 {
+			fmt.Println(errorStyle.Render("Usage: dns-switcher backups show <id>"))
+			return 2
+		}
+		b, err := FindBackup(args[1])
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		lines := []string{
+			labelStyle.Render("Created:  ") + infoStyle.Render(b.Created.Format("2006-01-02 15:04:05")),
+			labelStyle.Render("Provider: ") + infoStyle.Render(b.Provider),
+			labelStyle.Render("Backend:  ") + infoStyle.Render(b.Backend),
+			labelStyle.Render("Target:   ") + infoStyle.Render(b.Target),
+			labelStyle.Render("Servers:  ") + infoStyle.Render(strings.Join(b.Servers, " ")),
+		}
+		printBox("Backup "+b.ID, lines)
+		if b.Content != "" {
+			fmt.Println(b.Content)
+		}
+		return 0
+
+	case "restore":
+		if len(args) < 2 {
+			fmt.Println(errorStyle.Render("Usage: dns-switcher backups restore <id>"))
+			return 2
+		}
+		if !requireAdmin() {
+			return 1
+		}
+		b, err := FindBackup(args[1])
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		if err := RestoreBackup(b); err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		printBox("Backup Restored", []string{
+			successStyle.Render("Restored " + b.ID),
+			infoStyle.Render(strings.Join(b.Servers, " ")),
+		})
+		return 0
+
+	case "prune":
+		fs := flag.NewFlagSet("backups prune", flag.ContinueOnError)
+		keep := fs.Int("keep", config.Backups.Keep, "number of backups to keep (0 = unlimited)")
+		maxAge := fs.Int("max-age", config.Backups.MaxAgeDays, "delete backups older than this many days (0 = never)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return 2
+		}
+		if !requireAdmin() {
+			return 1
+		}
+		removed, err := PruneBackups(BackupPolicy{Keep: *keep, MaxAgeDays: *maxAge})
+		if err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			return 1
+		}
+		lines := []string{successStyle.Render(fmt.Sprintf("Removed %d backup(s)", len(removed)))}
+		for _, b := range removed {
+			lines = append(lines, formatBackupLine(b))
+		}
+		printBox("Prune", lines)
+		return 0
+	}
+
+	fmt.Println(errorStyle.Render("Unknown backups command: " + args[0]))
+	printUsage()
+	return 2
+}
+
+func formatBackupLine(b Backup) string {
+	provider := b.Provider
+	if provider == "" {
+		provider = "-"
+	}
+	return fmt.Sprintf("%s  %s  %s",
+		infoStyle.Render(fmt.Sprintf("%-22s", b.ID)),
+		b.Created.Format("01-02 15:04"),
+		provider)
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package main
+
+import "testing"
+
+func TestSplitApplyNotEnforced(t *testing.T) {
+	useFakeBackend(t)
+	useSplitRules(t, newStubServer(t, answerWith("192.0.2.1")))
+
+	if code := runSplitCommand([]string{"apply"}); code != 1 {
+		t.Fatalf("split apply exited with %d while the system does not use the proxy, want 1", code)
+	}
+}
+
+func TestSplitApplyEnforced(t *testing.T) {
+	f := useFakeBackend(t)
+	useSplitRules(t, newStubServer(t, answerWith("192.0.2.1")))
+	if err := f.SetInterfaceServers("eth0", []string{"127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if code := runSplitCommand([]string{"apply"}); code != 0 {
+		t.Fatalf("split apply exited with %d, want 0", code)
+	}
+}
+
+func TestSplitRemoveCommand(t *testing.T) {
+	useFakeBackend(t)
+
+	if code := runSplitCommand([]string{"remove"}); code != 0 {
+		t.Fatalf("split remove exited with %d, want 0", code)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Config holds the user settings read from configPath().
+type Config struct {
+	Backups    BackupPolicy     `json:"backups"`
+	Backend    string           `json:"backend"`
+	Resolvconf ResolvconfConfig `json:"resolvconf"`
+	Resolved   ResolvedConfig   `json:"resolved"`
+	Drift      DriftConfig      `json:"drift"`
+	Daemon     DaemonConfig     `json:"daemon"`
+	Proxy      ProxyConfig      `json:"proxy"`
+	Split      []SplitRule      `json:"split"`
+	UI         UIConfig         `json:"ui"`
+}
+
+// BackupPolicy controls how many backups are kept. Zero disables a limit.
+type BackupPolicy struct {
+	Keep       int `json:"keep"`
+	MaxAgeDays int `json:"max_age_days"`
+}
+
+// ResolvconfConfig controls the record registered with resolvconf. Debian's
+// resolvconf orders records by name through /etc/resolvconf/interface-order,
+// which puts "lo.*" first; openresolv orders by Metric, lowest first, and
+// with Exclusive set uses only our record.
+type ResolvconfConfig struct {
+	Record    string `json:"record"`
+	Metric    int    `json:"metric"`
+	Exclusive bool   `json:"exclusive"`
+}
+
+// ResolvedConfig overrides the DNSOverTLS= and DNSSEC= values of the
+// systemd-resolved drop-in. Empty values are derived from the provider.
+type ResolvedConfig struct {
+	DNSOverTLS string `json:"dns_over_tls"`
+	DNSSEC     string `json:"dnssec"`
+}
+
+// DriftConfig controls how the monitors watch for other programs changing
+// DNS after a switch.
+type DriftConfig struct {
+	IntervalSeconds int  `json:"interval_seconds"`
+	AutoReapply     bool `json:"auto_reapply"`
+}
+
+// DaemonConfig controls the failover daemon. Preferred is a provider name
+// or a comma separated list of servers; Providers lists the fallbacks by
+// name, all built-in providers if empty. A check slower than MaxLatencyMs
+// counts as a failure unless it is zero. After a switch the daemon stays put
+// for at least HoldSeconds, and only returns to the preferred provider once
+// it has passed RecoveryChecks checks in a row.
+type DaemonConfig struct {
+	Preferred       string   `json:"preferred"`
+	Providers       []string `json:"providers"`
+	IntervalSeconds int      `json:"interval_seconds"`
+	MaxFailures     int      `json:"max_failures"`
+	MaxLatencyMs    int      `json:"max_latency_ms"`
+	RecoveryChecks  int      `json:"recovery_checks"`
+	HoldSeconds     int      `json:"hold_seconds"`
+	LogFile         string   `json:"log_file"`
+}
+
+// ProxyConfig lists the addresses the local forwarding proxy listens on.
+type ProxyConfig struct {
+	Listen []string `json:"listen"`
+}
+
+// SplitRule sends lookups for Domain and its subdomains to Provider, a
+// provider name or a comma separated list of servers. Rules are matched in
+// order. With Interface set and systemd-resolved in use, the rule becomes a
+// routing domain of that interface; otherwise the local proxy enforces it.
+type SplitRule struct {
+	Domain    string `json:"domain"`
+	Provider  string `json:"provider"`
+	Interface string `json:"interface,omitempty"`
+}
+
+// UIConfig controls how the interfaces look. Theme names a built-in theme
+// (dark, light or high-contrast) or one of Themes, which are user-defined
+// themes keyed by name; see LoadTheme. Empty keeps the default look: the
+// dark theme in the terminal and the GUI's own palette. Plain drops colors,
+// box drawing and graphs from the terminal output for screen readers.
+type UIConfig struct {
+	Theme  string                     `json:"theme"`
+	Plain  bool                       `json:"plain"`
+	Themes map[string]json.RawMessage `json:"themes"`
+}
+
+var config = defaultConfig()
+
+func defaultConfig() Config {
+	return Config{
+		Backups: BackupPolicy{
+			Keep:       10,
+			MaxAgeDays: 30,
+		},
+		Backend: "auto",
+		Resolvconf: ResolvconfConfig{
+			Record: "lo.dns-switcher",
+		},
+		Drift: DriftConfig{
+			IntervalSeconds: 10,
+		},
+		Daemon: DaemonConfig{
+			IntervalSeconds: 30,
+			MaxFailures:     3,
+			MaxLatencyMs:    500,
+			RecoveryChecks:  5,
+			HoldSeconds:     300,
+		},
+		Proxy: ProxyConfig{
+			Listen: []string{"127.0.0.1:53", "[::1]:53"},
+		},
+	}
+}
+
+// LoadConfig reads the config file on top of the defaults. A missing file
+// is not an error.
+func LoadConfig() (Config, error) {
+	cfg := defaultConfig()
+
+	data, err := os.ReadFile(configPath())
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read %s: %w", configPath(), err)
+	}
+
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return defaultConfig(), fmt.Errorf("invalid config %s: %w", configPath(), err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Daemon keeps the system on a healthy provider. It checks the active
+// provider every interval, fails over to the fastest healthy fallback after
+// MaxFailures failed checks in a row, and returns to the preferred provider
+// once it has recovered. Every check and decision is logged.
+type Daemon struct {
+	cfg       DaemonConfig
+	preferred DNSProvider
+	fallbacks []DNSProvider
+	log       *log.Logger
+
+	// probe returns the latency of a provider in milliseconds, or -1 if it
+	// does not answer; apply switches the system to a provider.
+	probe func(DNSProvider) int
+	apply func(DNSProvider) error
+	now   func() time.Time
+
+	active     DNSProvider
+	failures   int
+	recoveries int
+	lastSwitch time.Time
+}
+
+// NewDaemon prepares a daemon for cfg. The preferred provider is looked up
+// by name or parsed as a list of servers; fallbacks are the named providers,
+// or the ones automatic selection may pick, minus the preferred one.
+func NewDaemon(cfg DaemonConfig, logger *log.Logger) (*Daemon, error) {
+	defaults := defaultConfig().Daemon
+	if cfg.IntervalSeconds <= 0 {
+		cfg.IntervalSeconds = defaults.IntervalSeconds
+	}
+	if cfg.MaxFailures <= 0 {
+		cfg.MaxFailures = defaults.MaxFailures
+	}
+	if cfg.RecoveryChecks <= 0 {
+		cfg.RecoveryChecks = defaults.RecoveryChecks
+	}
+	if cfg.MaxLatencyMs < 0 || cfg.HoldSeconds < 0 {
+		return nil, fmt.Errorf("max_latency_ms and hold_seconds must not be negative")
+	}
+
+	if cfg.Preferred == "" {
+		return nil, fmt.Errorf("no preferred provider configured")
+	}
+	preferred, err := resolveProvider(cfg.Preferred)
+	if err != nil {
+		return nil, err
+	}
+
+	var fallbacks []DNSProvider
+	if len(cfg.Providers) == 0 {
+		fallbacks = AutoCandidates()
+	} else {
+		for _, name := range cfg.Providers {
+			p, err := resolveProvider(name)
+			if err != nil {
+				return nil, err
+			}
+			fallbacks = append(fallbacks, p)
+		}
+	}
+	for i := 0; i < len(fallbacks); i++ {
+		if sameServers(fallbacks[i].Servers, preferred.Servers) {
+			fallbacks = append(fallbacks[:i], fallbacks[i+1:]...)
+			i--
+		}
+	}
+
+	return &Daemon{
+		cfg:       cfg,
+		preferred: preferred,
+		fallbacks: fallbacks,
+		log:       logger,
+		probe:     probeProvider,
+		apply:     switchProvider,
+		now:       time.Now,
+	}, nil
+}
+
+// resolveProvider resolves a provider name or a list of servers.
+func resolveProvider(name string) (DNSProvider, error) {
+	if p, ok := FindProvider(name); ok && len(p.Servers) > 0 {
+		return p, nil
+	}
+
+	servers := parseCustomDNS(name)
+	if err := validateServers(servers); err != nil {
+		return DNSProvider{}, fmt.Errorf("unknown provider %q", name)
+	}
+	return DNSProvider{Name: strings.Join(servers, ", "), Servers: servers, Latency: -1}, nil
+}
+
+// probeProvider returns the latency of the fastest server of p, or -1 if
+// none of them answers.
+func probeProvider(p DNSProvider) int {
+	best := -1
+	for _, server := range p.Servers {
+		if latency := TestDNSLatency(server); latency >= 0 && (best < 0 || latency < best) {
+			best = latency
+		}
+	}
+	return best
+}
+
+// switchProvider applies p and makes the system pick it up.
+func switchProvider(p DNSProvider) error {
+	if _, err := ApplyProvider(p); err != nil {
+		return err
+	}
+	if err := currentBackend().Flush(); err != nil {
+		return err
+	}
+	FlushCaches()
+	return nil
+}
+
+// healthy reports whether a check with this latency passes.
+func (d *Daemon) healthy(latency int) bool {
+	return latency >= 0 && (d.cfg.MaxLatencyMs == 0 || latency <= d.cfg.MaxLatencyMs)
+}
+
+func describeLatency(latency int) string {
+	if latency < 0 {
+		return "no answer"
+	}
+	return fmt.Sprintf("%dms", latency)
+}
+
+// Run switches to the preferred provider unless it is already in use and
+// then checks every interval until ctx is done.
+func (d *Daemon) Run(ctx context.Context) error {
+	d.log.Printf("starting: preferred %s, %d fallback(s), check every %ds, fail over after %d failure(s)",
+		d.preferred.Name, len(d.fallbacks), d.cfg.IntervalSeconds, d.cfg.MaxFailures)
+
+	if _, drifted, err := CheckDrift(d.preferred.Servers); err != nil || drifted {
+		d.log.Printf("switching to preferred provider %s", d.preferred.Name)
+		if err := d.apply(d.preferred); err != nil {
+			return fmt.Errorf("failed to apply %s: %w", d.preferred.Name, err)
+		}
+	} else {
+		d.log.Printf("preferred provider %s already in use", d.preferred.Name)
+	}
+	d.active = d.preferred
+	d.lastSwitch = d.now()
+
+	ticker := time.NewTicker(time.Duration(d.cfg.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		d.Check()
+
+		select {
+		case <-ctx.Done():
+			d.log.Printf("stopping, leaving %s in place", d.active.Name)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check runs one health check and acts on it.
+func (d *Daemon) Check() {
+	latency := d.probe(d.active)
+	if !d.healthy(latency) {
+		d.failures++
+		d.recoveries = 0
+		d.log.Printf("check %s: %s, %d failure(s) in a row", d.active.Name, describeLatency(latency), d.failures)
+		if d.failures >= d.cfg.MaxFailures {
+			d.failover()
+		}
+		return
+	}
+
+	d.failures = 0
+	if sameServers(d.active.Servers, d.preferred.Servers) {
+		d.log.Printf("check %s: %s, ok", d.active.Name, describeLatency(latency))
+		return
+	}
+
+	// Running on a fallback: watch for the preferred provider to recover.
+	preferred := d.probe(d.preferred)
+	if !d.healthy(preferred) {
+		d.recoveries = 0
+		d.log.Printf("check %s: %s, ok; preferred %s: %s, staying",
+			d.active.Name, describeLatency(latency), d.preferred.Name, describeLatency(preferred))
+		return
+	}
+
+	d.recoveries++
+	hold := time.Duration(d.cfg.HoldSeconds)*time.Second - d.now().Sub(d.lastSwitch)
+	switch {
+	case d.recoveries < d.cfg.RecoveryChecks:
+		d.log.Printf("check %s: %s, ok; preferred %s: %s, recovering %d/%d",
+			d.active.Name, describeLatency(latency), d.preferred.Name, describeLatency(preferred), d.recoveries, d.cfg.RecoveryChecks)
+	case hold > 0:
+		d.log.Printf("check %s: %s, ok; preferred %s recovered, holding for another %s",
+			d.active.Name, describeLatency(latency), d.preferred.Name, hold.Round(time.Second))
+	default:
+		d.log.Printf("preferred %s recovered (%s), switching back", d.preferred.Name, describeLatency(preferred))
+		d.switchTo(d.preferred)
+	}
+}
+
+// failover switches to the fastest healthy fallback. If none is healthy it
+// stays on the active provider and tries again after the next failed check.
+func (d *Daemon) failover() {
+	var candidates []DNSProvider
+	for _, p := range append([]DNSProvider{d.preferred}, d.fallbacks...) {
+		if !sameServers(p.Servers, d.active.Servers) {
+			candidates = append(candidates, p)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(p *DNSProvider) {
+			defer wg.Done()
+			p.Latency = d.probe(*p)
+		}(&candidates[i])
+	}
+	wg.Wait()
+
+	var healthy []DNSProvider
+	for _, p := range candidates {
+		if d.healthy(p.Latency) {
+			healthy = append(healthy, p)
+		}
+	}
+	if len(healthy) == 0 {
+		d.log.Printf("no healthy provider to fail over to, staying on %s", d.active.Name)
+		return
+	}
+	sort.SliceStable(healthy, func(i, j int) bool {
+		return healthy[i].Latency < healthy[j].Latency
+	})
+
+	d.log.Printf("failing over from %s to %s (%s)", d.active.Name, healthy[0].Name, describeLatency(healthy[0].Latency))
+	d.switchTo(healthy[0])
+}
+
+func (d *Daemon) switchTo(p DNSProvider) {
+	if err := d.apply(p); err != nil {
+		d.log.Printf("switch to %s failed: %v", p.Name, err)
+		return
+	}
+
+	d.log.Printf("now using %s (%s)", p.Name, strings.Join(p.Servers, ", "))
+	d.active = p
+	d.failures = 0
+	d.recoveries = 0
+	d.lastSwitch = d.now()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	primary   = DNSProvider{Name: "Primary", Servers: []string{"10.0.0.1"}}
+	secondary = DNSProvider{Name: "Secondary", Servers: []string{"10.0.0.2"}}
+	tertiary  = DNSProvider{Name: "Tertiary", Servers: []string{"10.0.0.3"}}
+)
+
+// testNetwork stands in for the providers a daemon probes and the system it
+// switches.
+type testNetwork struct {
+	mu       sync.Mutex
+	latency  map[string]int
+	applied  []string
+	applyErr error
+	clock    time.Time
+}
+
+func (n *testNetwork) set(name string, latency int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.latency[name] = latency
+}
+
+func (n *testNetwork) probe(p DNSProvider) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.latency[p.Name]
+}
+
+func (n *testNetwork) apply(p DNSProvider) error {
+	if n.applyErr != nil {
+		return n.applyErr
+	}
+	n.applied = append(n.applied, p.Name)
+	return nil
+}
+
+func (n *testNetwork) advance(d time.Duration) {
+	n.clock = n.clock.Add(d)
+}
+
+// newTestDaemon returns a daemon running on primary, just switched to,
+// with secondary and tertiary as fallbacks.
+func newTestDaemon(cfg DaemonConfig) (*Daemon, *testNetwork) {
+	n := &testNetwork{
+		latency: map[string]int{"Primary": 10, "Secondary": 40, "Tertiary": 20},
+		clock:   time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+	d := &Daemon{
+		cfg:        cfg,
+		preferred:  primary,
+		fallbacks:  []DNSProvider{secondary, tertiary},
+		log:        log.New(io.Discard, "", 0),
+		probe:      n.probe,
+		apply:      n.apply,
+		now:        func() time.Time { return n.clock },
+		active:     primary,
+		lastSwitch: n.clock,
+	}
+	return d, n
+}
+
+func checkApplied(t *testing.T, n *testNetwork, want ...string) {
+	t.Helper()
+	if len(n.applied)+len(want) > 0 && !reflect.DeepEqual(n.applied, want) {
+		t.Fatalf("switched to %v, want %v", n.applied, want)
+	}
+}
+
+func TestDaemonFailoverAfterMaxFailures(t *testing.T) {
+	d, n := newTestDaemon(DaemonConfig{MaxFailures: 3, RecoveryChecks: 1})
+	n.set("Primary", -1)
+
+	d.Check()
+	d.Check()
+	checkApplied(t, n)
+
+	// A passing check in between starts the count again.
+	n.set("Primary", 10)
+	d.Check()
+	n.set("Primary", -1)
+	d.Check()
+	d.Check()
+	checkApplied(t, n)
+
+	d.Check()
+	checkApplied(t, n, "Tertiary")
+	if d.active.Name != "Tertiary" || d.failures != 0 {
+		t.Fatalf("active %s with %d failures, want Tertiary with none", d.active.Name, d.failures)
+	}
+}
+
+func TestDaemonSlowChecksAreFailures(t *testing.T) {
+	d, n := newTestDaemon(DaemonConfig{MaxFailures: 2, MaxLatencyMs: 100, RecoveryChecks: 1})
+	n.set("Primary", 250)
+	// Tertiary answers, but too slowly to be a candidate.
+	n.set("Tertiary", 150)
+
+	d.Check()
+	checkApplied(t, n)
+	d.Check()
+	checkApplied(t, n, "Secondary")
+}
+
+func TestDaemonSlowChecksWithoutLimit(t *testing.T) {
+	d, n := newTestDaemon(DaemonConfig{MaxFailures: 1, RecoveryChecks: 1})
+	n.set("Primary", 2500)
+
+	for i := 0; i < 5; i++ {
+		d.Check()
+	}
+	checkApplied(t, n)
+}
+
+func TestDaemonNoHealthyFallback(t *testing.T) {
+	d, n := newTestDaemon(DaemonConfig{MaxFailures: 1, MaxLatencyMs: 100, RecoveryChecks: 1})
+	n.set("Primary", -1)
+	n.set("Secondary", -1)
+	n.set("Tertiary", 500)
+
+	d.Check()
+	d.Check()
+	checkApplied(t, n)
+	if d.active.Name != "Primary" {
+		t.Fatalf("active %s, want Primary", d.active.Name)
+	}
+
+	// Each further failure tries again.
+	n.set("Secondary", 30)
+	d.Check()
+	checkApplied(t, n, "Secondary")
+}
+
+func TestDaemonFailedSwitch(t *testing.T) {
+	d, n := newTestDaemon(DaemonConfig{MaxFailures: 1, RecoveryChecks: 1})
+	n.set("Primary", -1)
+	n.applyErr = errors.New("permission denied")
+
+	d.Check()
+	if d.active.Name != "Primary" {
+		t.Fatalf("active %s after a failed switch, want Primary", d.active.Name)
+	}
+}
+
+func TestDaemonRecoveryHysteresis(t *testing.T) {
+	d, n := newTestDaemon(DaemonConfig{MaxFailures: 1, RecoveryChecks: 3, HoldSeconds: 300})
+	n.set("Primary", -1)
+	d.Check()
+	checkApplied(t, n, "Tertiary")
+
+	// The preferred provider comes back, but a failed check in the middle
+	// restarts the count.
+	n.set("Primary", 10)
+	n.advance(10 * time.Second)
+	d.Check()
+	n.advance(10 * time.Second)
+	d.Check()
+	n.set("Primary", -1)
+	n.advance(10 * time.Second)
+	d.Check()
+	if d.recoveries != 0 {
+		t.Fatalf("%d recoveries after a failed check of the preferred provider, want 0", d.recoveries)
+	}
+
+	n.set("Primary", 10)
+	for i := 0; i < 3; i++ {
+		n.advance(10 * time.Second)
+		d.Check()
+	}
+	checkApplied(t, n, "Tertiary")
+	if d.recoveries != 3 {
+		t.Fatalf("%d recoveries, want 3", d.recoveries)
+	}
+
+	// Recovered, but the hold time since the failover has not passed.
+	n.advance(200 * time.Second)
+	d.Check()
+	checkApplied(t, n, "Tertiary")
+
+	n.advance(60 * time.Second)
+	d.Check()
+	checkApplied(t, n, "Tertiary", "Primary")
+	if d.active.Name != "Primary" || d.recoveries != 0 {
+		t.Fatalf("active %s with %d recoveries, want Primary with none", d.active.Name, d.recoveries)
+	}
+}
+
+func TestDaemonRecoveryWithoutHold(t *testing.T) {
+	d, n := newTestDaemon(DaemonConfig{MaxFailures: 1, RecoveryChecks: 2})
+	n.set("Primary", -1)
+	d.Check()
+	checkApplied(t, n, "Tertiary")
+
+	n.set("Primary", 10)
+	d.Check()
+	checkApplied(t, n, "Tertiary")
+	d.Check()
+	checkApplied(t, n, "Tertiary", "Primary")
+}
@@ -0,0 +1,223 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// serverLatencyMsg carries the result of testing one server of the
+// provider in the detail pane.
+type serverLatencyMsg struct {
+	server  string
+	latency int
+}
+
+func serverLatencyCmd(server string) tea.Cmd {
+	return func() tea.Msg {
+		return serverLatencyMsg{server: server, latency: TestDNSLatency(server)}
+	}
+}
+
+// detailProvider returns the provider shown in the detail pane.
+func (m model) detailProvider() (DNSProvider, int) {
+	for i, provider := range providers {
+		if provider.Name == m.detailName {
+			return provider, i
+		}
+	}
+	return DNSProvider{}, -1
+}
+
+// openDetail shows the detail pane of provider and tests its servers if
+// that has not been done yet.
+func (m model) openDetail(provider DNSProvider) (model, tea.Cmd) {
+	m.screen = screenDetail
+	m.detailName = provider.Name
+	m.detailStatus = ""
+
+	for _, server := range provider.Servers {
+		if _, tested := m.serverLatency[server]; !tested {
+			return m.testDetail()
+		}
+	}
+	return m, nil
+}
+
+// testDetail tests every server of the provider in the detail pane, and
+// the provider as a whole so the test lands in its history.
+func (m model) testDetail() (model, tea.Cmd) {
+	provider, _ := m.detailProvider()
+	if !testable(provider) {
+		return m, nil
+	}
+
+	var cmds []tea.Cmd
+	for _, server := range provider.Servers {
+		if !m.serverTesting[server] {
+			m.serverTesting[server] = true
+			cmds = append(cmds, serverLatencyCmd(server))
+		}
+	}
+
+	var test tea.Cmd
+	m, test = m.testProviders([]DNSProvider{provider})
+	return m, tea.Batch(append(cmds, test)...)
+}
+
+func (m model) updateDetail(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	provider, index := m.detailProvider()
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "backspace", "tab", "i":
+		m.screen = screenSelect
+
+	case "enter", "a":
+		if index < 0 {
+			break
+		}
+		if provider.Name == "Add Custom DNS" {
+			m.screen = screenSelect
+			m.inputMode = true
+			m.customInput = ""
+			m.customError = ""
+			break
+		}
+		return m.confirm(provider)
+
+	case "t":
+		return m.testDetail()
+
+	case "e":
+		if !testable(provider) {
+			m.detailStatus = "Only providers with servers can be edited"
+			break
+		}
+		m.editing = provider.Name
+		m.inputMode = true
+		m.customInput = strings.Join(provider.Servers, ", ")
+		m.customError = ""
+
+	case "f":
+		if !testable(provider) {
+			m.detailStatus = "Only providers with servers can be favorites"
+			break
+		}
+		var err error
+		if m, err = m.toggleFavorite(provider); err != nil {
+			m.detailStatus = "Could not save favorites: " + err.Error()
+		}
+	}
+
+	return m, nil
+}
+
+// editServers replaces the servers of the provider being edited and
+// re-tests it.
+func (m model) editServers(servers []string) (model, tea.Cmd) {
+	for i := range providers {
+		if providers[i].Name == m.editing {
+			providers[i].Servers = servers
+			providers[i].Latency = -1
+		}
+	}
+
+	m.editing = ""
+	m.inputMode = false
+	m.customInput = ""
+	m.detailStatus = "Servers updated for this session"
+	return m.testDetail()
+}
+
+func (m model) detailView() string {
+	var b strings.Builder
+	provider, _ := m.detailProvider()
+
+	name := provider.Name
+	if IsFavorite(provider.Name) {
+		name = symbol("★ ", "* ") + name
+	}
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("  "+name) + "\n")
+	if len(provider.Categories) > 0 {
+		b.WriteString(helpStyle.Render("  "+strings.Join(provider.Categories, " • ")) + "\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(headerStyle.Render("  Servers:") + "\n")
+	if len(provider.Servers) == 0 {
+		b.WriteString(infoStyle.Render("    none") + "\n")
+	}
+	for _, server := range provider.Servers {
+		latency, tested := m.serverLatency[server]
+		var result string
+		switch {
+		case m.serverTesting[server]:
+			result = serverStyle.Render(m.spinnerFrame())
+		case !tested:
+			result = helpStyle.Render("not tested")
+		default:
+			label, style := latencyLabel(latency)
+			result = style.Render(label)
+		}
+		b.WriteString("    • " + serverStyle.Render(fmt.Sprintf("%-40s", server)) + " " + result + "\n")
+	}
+	b.WriteString("\n")
+
+	transports := "UDP/TCP port 53"
+	if provider.TLSName != "" {
+		transports += ", DNS over TLS (" + provider.TLSName + ")"
+	}
+	dnssec := "no"
+	if provider.DNSSEC {
+		dnssec = "validates"
+	}
+	filtering := "none"
+	if provider.InCategory("filtering") {
+		filtering = "blocks malicious domains"
+	}
+	b.WriteString(fmt.Sprintf("  %s %s\n", headerStyle.Render("Transports:"), infoStyle.Render(transports)))
+	b.WriteString(fmt.Sprintf("  %s %s\n", headerStyle.Render("DNSSEC:    "), infoStyle.Render(dnssec)))
+	b.WriteString(fmt.Sprintf("  %s %s\n", headerStyle.Render("Filtering: "), infoStyle.Render(filtering)))
+
+	lastTest := "never"
+	if tested, ok := m.tested[provider.Name]; ok {
+		lastTest = tested.Format("15:04:05") + " (" + formatDuration(int(time.Since(tested).Seconds())) + " ago)"
+	}
+	b.WriteString(fmt.Sprintf("  %s %s\n", headerStyle.Render("Last test: "), infoStyle.Render(lastTest)))
+
+	if history := m.history[provider.Name]; len(history.Samples()) > 0 {
+		summary := history.Summary()
+		graph := ""
+		if !plainOutput {
+			graph = serverStyle.Render(sparkline(history.Samples(), m.graphWidth())) + "  "
+		}
+		b.WriteString(fmt.Sprintf("  %s %s%s\n",
+			headerStyle.Render("History:   "),
+			graph,
+			helpStyle.Render(fmt.Sprintf("%d tests, avg %dms, loss %.0f%%", summary.Count, summary.Avg, summary.Loss))))
+	}
+	b.WriteString("\n")
+
+	if m.detailStatus != "" {
+		b.WriteString(infoStyle.Render("  "+m.detailStatus) + "\n\n")
+	}
+
+	favorite := "favorite"
+	if IsFavorite(provider.Name) {
+		favorite = "unfavorite"
+	}
+	for _, line := range wrapHelp(m.termWidth, "enter/a: apply", "t: test", "e: edit servers", "f: "+favorite, "esc: back", "q: quit") {
+		b.WriteString(helpStyle.Render(line) + "\n")
+	}
+
+	return b.String()
+}
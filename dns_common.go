@@ -4,11 +4,21 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 )
 
+// dialDNS opens connections to DNS servers. It is replaced when another
+// network namespace is targeted.
+var dialDNS = func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	d := net.Dialer{Timeout: timeout}
+	return d.DialContext(ctx, network, address)
+}
+
 func ValidateDNS(servers []string) (bool, error) {
 	testDomain := "google.com"
 
@@ -16,10 +26,7 @@ func ValidateDNS(servers []string) (bool, error) {
 		resolver := &net.Resolver{
 			PreferGo: true,
 			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				d := net.Dialer{
-					Timeout: 3 * time.Second,
-				}
-				return d.Dial(network, net.JoinHostPort(server, "53"))
+				return dialDNS(ctx, network, net.JoinHostPort(server, "53"), 3*time.Second)
 			},
 		}
 
@@ -40,10 +47,7 @@ func TestDNSLatency(server string) int {
 	resolver := &net.Resolver{
 		PreferGo: true,
 		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-			d := net.Dialer{
-				Timeout: 2 * time.Second,
-			}
-			return d.Dial(network, net.JoinHostPort(server, "53"))
+			return dialDNS(ctx, network, net.JoinHostPort(server, "53"), 2*time.Second)
 		},
 	}
 
@@ -63,6 +67,12 @@ func TestDNSLatency(server string) int {
 	return int(elapsed)
 }
 
+// testable reports whether a provider has servers whose latency can be
+// tested.
+func testable(provider DNSProvider) bool {
+	return len(provider.Servers) > 0 && provider.Name != "Reset to Default" && provider.Name != "Add Custom DNS"
+}
+
 func TestAllProviders() {
 	for i := range providers {
 		if providers[i].Name == "Reset to Default" || providers[i].Name == "Add Custom DNS" {
@@ -77,29 +87,59 @@ func TestAllProviders() {
 	}
 }
 
+// SortProvidersByLatency sorts the providers fastest first, failed ones
+// last, keeping favorites at the top.
 func SortProvidersByLatency() {
-	var special []DNSProvider
-	var normal []DNSProvider
+	sortProviders(faster)
+}
+
+// SortProvidersByName sorts the providers alphabetically, keeping
+// favorites at the top.
+func SortProvidersByName() {
+	sortProviders(func(a, b DNSProvider) bool {
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+}
+
+// PinFavorites moves the favorites to the top without sorting.
+func PinFavorites() {
+	sortProviders(func(a, b DNSProvider) bool { return false })
+}
+
+// sortProviders sorts the favorites and then the other providers with
+// less, keeping "Reset to Default" and "Add Custom DNS" at the end.
+func sortProviders(less func(a, b DNSProvider) bool) {
+	var favorite, normal, special []DNSProvider
 
 	for _, p := range providers {
-		if p.Name == "Reset to Default" || p.Name == "Add Custom DNS" {
+		switch {
+		case p.Name == "Reset to Default" || p.Name == "Add Custom DNS":
 			special = append(special, p)
-		} else {
+		case IsFavorite(p.Name):
+			favorite = append(favorite, p)
+		default:
 			normal = append(normal, p)
 		}
 	}
 
-	sort.Slice(normal, func(i, j int) bool {
-		if normal[i].Latency == -1 {
-			return false
-		}
-		if normal[j].Latency == -1 {
-			return true
-		}
-		return normal[i].Latency < normal[j].Latency
-	})
+	for _, group := range [][]DNSProvider{favorite, normal} {
+		sort.SliceStable(group, func(i, j int) bool {
+			return less(group[i], group[j])
+		})
+	}
+
+	providers = append(append(favorite, normal...), special...)
+}
 
-	providers = append(normal, special...)
+// faster orders providers by latency, failed ones last.
+func faster(a, b DNSProvider) bool {
+	if a.Latency == -1 {
+		return false
+	}
+	if b.Latency == -1 {
+		return true
+	}
+	return a.Latency < b.Latency
 }
 
 func formatDuration(seconds int) string {
@@ -128,3 +168,43 @@ func parseCustomDNS(input string) []string {
 	}
 	return servers
 }
+
+// validateServers checks that every server is a plain IPv4 or IPv6
+// address. Servers end up in resolv.conf lines and PowerShell scripts, so
+// anything else, including zones and ports, is rejected.
+func validateServers(servers []string) error {
+	if len(servers) == 0 {
+		return fmt.Errorf("no DNS servers given")
+	}
+	for _, server := range servers {
+		addr, err := netip.ParseAddr(server)
+		if err != nil || addr.Zone() != "" {
+			return fmt.Errorf("invalid DNS server %q: not an IP address", server)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic replaces path with data through a rename, so readers never
+// see a half written file and a symlink at path is replaced, not followed.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -5,89 +5,47 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"strings"
+	"path/filepath"
 )
 
+func init() {
+	registerBackend("networksetup", 50, func() bool { return true }, func() (DNSBackend, error) {
+		return networksetupBackend{run: execRunner{}}, nil
+	})
+}
+
 func IsAdmin() bool {
 	return os.Geteuid() == 0
 }
 
-func GetCurrentDNS() ([]string, error) {
-	service, err := getActiveNetworkService()
-	if err != nil {
-		return nil, err
-	}
-
-	cmd := exec.Command("networksetup", "-getdnsservers", service)
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get DNS servers: %w", err)
-	}
-
-	result := strings.TrimSpace(string(output))
-	if result == "There aren't any DNS Servers set on "+service+"." {
-		return []string{}, nil
-	}
-
-	dnsServers := strings.Split(result, "\n")
-	return dnsServers, nil
+func systemStateDir() string {
+	return "/Library/Application Support/dns-switcher"
 }
 
-func getActiveNetworkService() (string, error) {
-	cmd := exec.Command("networksetup", "-listallnetworkservices")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to list network services: %w", err)
-	}
-
-	services := strings.Split(string(output), "\n")
-	for _, service := range services {
-		service = strings.TrimSpace(service)
-		if service == "" || strings.HasPrefix(service, "*") {
-			continue
-		}
-
-		if strings.Contains(service, "Wi-Fi") || strings.Contains(service, "Ethernet") {
-			return service, nil
-		}
-	}
+func userStateDir() (string, error) {
+	return os.UserConfigDir()
+}
 
-	for _, service := range services {
-		service = strings.TrimSpace(service)
-		if service != "" && !strings.HasPrefix(service, "*") && !strings.HasPrefix(service, "An asterisk") {
-			return service, nil
-		}
+// SetTarget only supports the running system on macOS.
+func SetTarget(root, netns string) error {
+	if root != "" || netns != "" {
+		return fmt.Errorf("--root and --netns are only supported on Linux")
 	}
-
-	return "", fmt.Errorf("no active network service found")
+	return nil
 }
 
-func BackupResolvConf() (string, error) {
-	return "backup not needed on macOS", nil
+func targetName() string {
+	return "system"
 }
 
-func UpdateResolvConf(provider DNSProvider) error {
-	service, err := getActiveNetworkService()
-	if err != nil {
-		return err
-	}
-
-	args := []string{"-setdnsservers", service}
-	if provider.Name == "Reset to Default" {
-		args = append(args, "empty")
-	} else {
-		args = append(args, provider.Servers...)
-	}
-
-	cmd := exec.Command("networksetup", args...)
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to update DNS: %w", err)
-	}
+func systemLockPath() string {
+	return "/var/run/dns-switcher.lock"
+}
 
-	return nil
+func configPath() string {
+	return filepath.Join(systemStateDir(), "config.json")
 }
 
-func RestartSystemdResolved() error {
+func legacyBackups() []Backup {
 	return nil
 }
@@ -7,97 +7,353 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
-const resolvConfPath = "/etc/resolv.conf"
+const (
+	resolvConfPath   = "/etc/resolv.conf"
+	resolvedStubPath = "/run/systemd/resolve/stub-resolv.conf"
+)
+
+func init() {
+	registerBackend("resolv.conf", 50, func() bool { return true }, func() (DNSBackend, error) {
+		return resolvConfBackend{}, nil
+	})
+}
 
 func IsAdmin() bool {
 	return os.Geteuid() == 0
 }
 
-func GetCurrentDNS() ([]string, error) {
-	file, err := os.Open(resolvConfPath)
+// systemLockPath is the same for the running system and its namespaces;
+// serialising changes to different targets costs nothing. A root directory
+// keeps its lock with its state, so nothing outside the root is touched.
+func systemLockPath() string {
+	if activeTarget.root != "" {
+		return filepath.Join(systemStateDir(), "dns-switcher.lock")
+	}
+	return "/run/dns-switcher.lock"
+}
+
+func configPath() string {
+	return "/etc/dns-switcher/config.json"
+}
+
+// resolvConfBackend writes resolv.conf directly. It is the fallback for
+// systems where nothing else manages the file, and the only backend for
+// network namespaces.
+type resolvConfBackend struct{}
+
+func (resolvConfBackend) Name() string {
+	return "resolv.conf"
+}
+
+func (resolvConfBackend) Current() ([]string, error) {
+	path := activeTarget.path(resolvConfPath)
+	input, err := readTargetFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open %s: %w", resolvConfPath, err)
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
 	}
-	defer file.Close()
 
-	var dnsServers []string
-	scanner := bufio.NewScanner(file)
+	return parseNameservers(string(input)), nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, "nameserver") {
-			fields := strings.Fields(line)
-			if len(fields) >= 2 {
-				dnsServers = append(dnsServers, fields[1])
-			}
-		}
+func (resolvConfBackend) Apply(provider DNSProvider) error {
+	path := activeTarget.path(resolvConfPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+	// Replace the file rather than writing through it: when resolv.conf is
+	// a symlink into /run the link target belongs to another service.
+	if err := writeFileAtomic(path, []byte(resolvConfContent(provider)), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
 	}
 
-	return dnsServers, nil
+	return nil
 }
 
-func BackupResolvConf() (string, error) {
-	timestamp := time.Now().Format("20060102_150405")
-	backupPath := fmt.Sprintf("%s.bak.%s", resolvConfPath, timestamp)
+// PreviewFile returns resolv.conf as it is and as Apply would write it.
+func (resolvConfBackend) PreviewFile(provider DNSProvider) (string, string, string, error) {
+	path := activeTarget.path(resolvConfPath)
+	before, err := readTargetFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return "", "", "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return path, string(before), resolvConfContent(provider), nil
+}
 
-	input, err := os.ReadFile(resolvConfPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to read %s: %w", resolvConfPath, err)
+// resolvConfContent renders resolv.conf for a provider.
+func resolvConfContent(provider DNSProvider) string {
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("# Generated by dns-switcher on %s\n", time.Now().Format("2006-01-02 15:04:05")))
+	content.WriteString(fmt.Sprintf("# Provider: %s\n", provider.Name))
+
+	for _, dns := range provider.Servers {
+		content.WriteString(fmt.Sprintf("nameserver %s\n", dns))
 	}
 
-	err = os.WriteFile(backupPath, input, 0644)
-	if err != nil {
-		return "", fmt.Errorf("failed to create backup: %w", err)
+	content.WriteString("options edns0 trust-ad\n")
+
+	return content.String()
+}
+
+func (resolvConfBackend) Snapshot() (Snapshot, error) {
+	path := activeTarget.path(resolvConfPath)
+	snap := Snapshot{
+		Backend: "resolv.conf",
+		Target:  path,
+	}
+
+	// A namespace without its own resolv.conf uses the host's file.
+	if _, err := os.Lstat(path); os.IsNotExist(err) && !activeTarget.isSystem() {
+		snap.Missing = true
+		return snap, nil
+	}
+
+	if link, err := os.Readlink(path); err == nil {
+		snap.Link = link
 	}
 
-	return backupPath, nil
+	input, err := readTargetFile(path)
+	if err != nil && snap.Link == "" {
+		return Snapshot{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	snap.Servers = parseNameservers(string(input))
+	snap.Content = string(input)
+
+	return snap, nil
 }
 
-func UpdateResolvConf(provider DNSProvider) error {
-	_, err := BackupResolvConf()
-	if err != nil {
-		return fmt.Errorf("backup failed: %w", err)
+func (b resolvConfBackend) Restore(snap Snapshot) error {
+	if snap.Backend != "resolv.conf" {
+		return fmt.Errorf("cannot restore a %s snapshot with the resolv.conf backend", snap.Backend)
+	}
+	if snap.Target == "" {
+		snap.Target = activeTarget.path(resolvConfPath)
 	}
 
-	var content strings.Builder
-	content.WriteString(fmt.Sprintf("# Updated on %s\n", time.Now().Format("2006-01-02 15:04:05")))
+	if snap.DHCP && snap.Content == "" && snap.Link == "" {
+		return b.restoreDHCP(snap.Target)
+	}
 
-	if provider.Name != "Reset to Default" {
-		content.WriteString(fmt.Sprintf("# Provider: %s\n", provider.Name))
+	if snap.Missing {
+		if err := os.Remove(snap.Target); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", snap.Target, err)
+		}
+		return nil
 	}
 
-	for _, dns := range provider.Servers {
-		content.WriteString(fmt.Sprintf("nameserver %s\n", dns))
+	if err := os.MkdirAll(filepath.Dir(snap.Target), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(snap.Target), err)
 	}
 
-	if provider.Name != "Reset to Default" {
-		content.WriteString("options edns0 trust-ad\n")
+	if snap.Link != "" {
+		tmp := snap.Target + ".dns-switcher.tmp"
+		_ = os.Remove(tmp)
+		if err := os.Symlink(snap.Link, tmp); err != nil {
+			return fmt.Errorf("failed to link %s: %w", snap.Target, err)
+		}
+		if err := os.Rename(tmp, snap.Target); err != nil {
+			_ = os.Remove(tmp)
+			return fmt.Errorf("failed to link %s: %w", snap.Target, err)
+		}
+		return nil
 	}
 
-	err = os.WriteFile(resolvConfPath, []byte(content.String()), 0644)
+	err := writeFileAtomic(snap.Target, []byte(snap.Content), 0644)
 	if err != nil {
-		return fmt.Errorf("failed to write %s: %w", resolvConfPath, err)
+		return fmt.Errorf("failed to write %s: %w", snap.Target, err)
 	}
 
 	return nil
 }
 
-func RestartSystemdResolved() error {
-	cmd := exec.Command("systemctl", "is-active", "systemd-resolved")
-	err := cmd.Run()
+// restoreDHCP is used when no original configuration was recorded. It
+// hands resolv.conf back to systemd-resolved when that is running, or
+// otherwise writes the servers the DHCP client received. A namespace goes
+// back to the host's servers.
+func (b resolvConfBackend) restoreDHCP(path string) error {
+	if activeTarget.netns != "" {
+		return b.Restore(Snapshot{Backend: "resolv.conf", Target: path, Missing: true})
+	}
+	if activeTarget.root != "" {
+		return fmt.Errorf("no original configuration was recorded for %s", activeTarget.root)
+	}
+
+	if systemdResolvedActive() {
+		return b.Restore(Snapshot{Backend: "resolv.conf", Target: path, Link: resolvedStubPath})
+	}
+
+	servers := dhcpServers()
+	if len(servers) == 0 {
+		return fmt.Errorf("no original configuration was recorded and no DHCP-provided DNS servers were found")
+	}
+
+	var content strings.Builder
+	content.WriteString("# DNS servers provided by DHCP, restored by dns-switcher\n")
+	for _, dns := range servers {
+		content.WriteString(fmt.Sprintf("nameserver %s\n", dns))
+	}
+
+	if err := writeFileAtomic(path, []byte(content.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func (resolvConfBackend) Flush() error {
+	return reloadSystemdResolved()
+}
+
+func (resolvConfBackend) Restarts() []string {
+	return resolvedRestarts()
+}
+
+func (resolvConfBackend) Capabilities() Capabilities {
+	return Capabilities{NeedsAdmin: true, Flush: activeTarget.isSystem()}
+}
+
+// readTargetFile reads a file of the target. Inside a root directory an
+// absolute symlink such as /etc/resolv.conf -> /run/... points into the
+// root, not into the host.
+func readTargetFile(path string) ([]byte, error) {
+	if activeTarget.root != "" {
+		if link, err := os.Readlink(path); err == nil && filepath.IsAbs(link) {
+			path = filepath.Join(activeTarget.root, link)
+		}
+	}
+	return os.ReadFile(path)
+}
+
+func parseNameservers(content string) []string {
+	var dnsServers []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "nameserver") {
+			fields := strings.Fields(line)
+			if len(fields) >= 2 {
+				dnsServers = append(dnsServers, fields[1])
+			}
+		}
+	}
+
+	return dnsServers
+}
+
+// legacyBackups lists the resolv.conf.bak.<timestamp> files that older
+// versions left next to resolv.conf, so they can be restored and pruned.
+func legacyBackups() []Backup {
+	target := activeTarget.path(resolvConfPath)
+	matches, _ := filepath.Glob(target + ".bak.*")
+
+	var backups []Backup
+	for _, path := range matches {
+		stamp := strings.TrimPrefix(path, target+".bak.")
+		created, err := time.ParseInLocation("20060102_150405", stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			ID:      "legacy-" + stamp,
+			Created: created,
+			Snapshot: Snapshot{
+				Backend: "resolv.conf",
+				Target:  target,
+				Servers: parseNameservers(string(content)),
+				Content: string(content),
+			},
+			path: path,
+		})
+	}
+
+	return backups
+}
+
+// dhcpServers looks for the DNS servers of the current DHCP lease in the
+// places NetworkManager, systemd-networkd and dhclient leave them.
+func dhcpServers() []string {
+	for _, path := range []string{
+		"/run/NetworkManager/no-stub-resolv.conf",
+		"/run/NetworkManager/resolv.conf",
+	} {
+		if content, err := os.ReadFile(path); err == nil {
+			if servers := parseNameservers(string(content)); len(servers) > 0 {
+				return servers
+			}
+		}
+	}
+
+	leases, _ := filepath.Glob("/run/systemd/netif/leases/*")
+	for _, path := range leases {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.HasPrefix(line, "DNS=") {
+				return strings.Fields(strings.TrimPrefix(line, "DNS="))
+			}
+		}
+	}
+
+	var dhclient []string
+	for _, pattern := range []string{"/var/lib/dhcp/dhclient*.lease*", "/var/lib/dhclient/*.lease*"} {
+		matches, _ := filepath.Glob(pattern)
+		dhclient = append(dhclient, matches...)
+	}
+	var servers []string
+	for _, path := range dhclient {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// The newest lease comes last in the file, so keep overwriting.
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if !strings.HasPrefix(line, "option domain-name-servers ") {
+				continue
+			}
+			value := strings.TrimSuffix(strings.TrimPrefix(line, "option domain-name-servers "), ";")
+			servers = parseCustomDNS(value)
+		}
+	}
+
+	return servers
+}
+
+func systemdResolvedActive() bool {
+	cmd := exec.Command("systemctl", "is-active", "--quiet", "systemd-resolved")
+	return cmd.Run() == nil
+}
+
+// resolvedRestarts names the service reloadSystemdResolved reloads, if it
+// would reload one.
+func resolvedRestarts() []string {
+	if activeTarget.isSystem() && systemdResolvedActive() {
+		return []string{"systemd-resolved"}
+	}
+	return nil
+}
 
-	if err == nil {
-		cmd = exec.Command("systemctl", "restart", "systemd-resolved")
+// reloadSystemdResolved makes systemd-resolved pick up the new settings.
+// A reload re-reads resolved.conf and its drop-ins without dropping the
+// cache and open connections; systemctl falls back to a restart on versions
+// that cannot reload.
+func reloadSystemdResolved() error {
+	if activeTarget.isSystem() && systemdResolvedActive() {
+		cmd := exec.Command("systemctl", "reload-or-restart", "systemd-resolved")
 		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("failed to restart systemd-resolved: %w", err)
+			return fmt.Errorf("failed to reload systemd-resolved: %w", err)
 		}
 	}
 
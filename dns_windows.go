@@ -5,17 +5,23 @@ package main
 import (
 	"fmt"
 	"os"
-	"os/exec"
-	"strings"
+	"path/filepath"
 )
 
-func powershell(args ...string) *exec.Cmd {
+func init() {
+	registerBackend("powershell", 50, func() bool { return true }, func() (DNSBackend, error) {
+		return powershellBackend{run: execRunner{}, exe: powershellPath()}, nil
+	})
+}
+
+// powershellPath prefers PowerShell 7 over the built-in Windows PowerShell.
+func powershellPath() string {
 	pwsh := "C:\\Program Files\\PowerShell\\7\\pwsh.exe"
 	if _, err := os.Stat(pwsh); err == nil {
-		return exec.Command(pwsh, args...)
+		return pwsh
 	}
 
-	return exec.Command("C:\\Windows\\System32\\WindowsPowerShell\\v1.0\\powershell.exe", args...)
+	return "C:\\Windows\\System32\\WindowsPowerShell\\v1.0\\powershell.exe"
 }
 
 func IsAdmin() bool {
@@ -26,83 +32,38 @@ func IsAdmin() bool {
 	return true
 }
 
-func GetCurrentDNS() ([]string, error) {
-	adapter, err := getActiveNetworkAdapter()
-	if err != nil {
-		return nil, err
+func systemStateDir() string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = "C:\\ProgramData"
 	}
-
-	cmd := powershell("-Command",
-		fmt.Sprintf("(Get-DnsClientServerAddress -InterfaceAlias '%s' -AddressFamily IPv4).ServerAddresses", adapter))
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get DNS servers: %w", err)
-	}
-
-	result := strings.TrimSpace(string(output))
-	if result == "" {
-		return []string{}, nil
-	}
-
-	lines := strings.Split(result, "\r\n")
-	var dnsServers []string
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			dnsServers = append(dnsServers, line)
-		}
-	}
-
-	return dnsServers, nil
+	return filepath.Join(programData, "dns-switcher")
 }
 
-func getActiveNetworkAdapter() (string, error) {
-	cmd := powershell("-Command",
-		"Get-NetAdapter | Where-Object {$_.Status -eq 'Up'} | Select-Object -First 1 -ExpandProperty Name")
-	output, err := cmd.Output()
-	if err != nil {
-		return "", fmt.Errorf("failed to get network adapter: %w", err)
-	}
+func userStateDir() (string, error) {
+	return os.UserConfigDir()
+}
 
-	adapter := strings.TrimSpace(string(output))
-	if adapter == "" {
-		return "", fmt.Errorf("no active network adapter found")
+// SetTarget only supports the running system on Windows.
+func SetTarget(root, netns string) error {
+	if root != "" || netns != "" {
+		return fmt.Errorf("--root and --netns are only supported on Linux")
 	}
-
-	return adapter, nil
+	return nil
 }
 
-func BackupResolvConf() (string, error) {
-	return "backup not needed on Windows", nil
+func targetName() string {
+	return "system"
 }
 
-func UpdateResolvConf(provider DNSProvider) error {
-	adapter, err := getActiveNetworkAdapter()
-	if err != nil {
-		return err
-	}
-
-	var cmd *exec.Cmd
-
-	if provider.Name == "Reset to Default" {
-		cmd = powershell("-Command",
-			fmt.Sprintf("Set-DnsClientServerAddress -InterfaceAlias '%s' -ResetServerAddresses", adapter))
-	} else {
-		servers := strings.Join(provider.Servers, ",")
-		cmd = powershell("-Command",
-			fmt.Sprintf("Set-DnsClientServerAddress -InterfaceAlias '%s' -ServerAddresses %s", adapter, servers))
-	}
-
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to update DNS: %w (make sure you are running as Administrator)", err)
-	}
-
-	flushCmd := exec.Command("C:\\Windows\\System32\\ipconfig.exe", "/flushdns")
-	_ = flushCmd.Run()
+func systemLockPath() string {
+	return filepath.Join(systemStateDir(), "dns-switcher.lock")
+}
 
-	return nil
+func configPath() string {
+	return filepath.Join(systemStateDir(), "config.json")
 }
 
-func RestartSystemdResolved() error {
+func legacyBackups() []Backup {
 	return nil
 }
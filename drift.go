@@ -0,0 +1,97 @@
+package main
+
+import "fmt"
+
+// driftInterval is how many seconds the monitors wait between drift checks.
+func driftInterval() int {
+	if config.Drift.IntervalSeconds <= 0 {
+		return defaultConfig().Drift.IntervalSeconds
+	}
+	return config.Drift.IntervalSeconds
+}
+
+// CheckDrift compares the servers in use with the ones we set, on the
+// selected interfaces if any. It returns the servers found and whether
+// something else has replaced ours since the switch.
+func CheckDrift(intended []string) ([]string, bool, error) {
+	backend := currentBackend()
+
+	if ib, ok := backend.(interfaceBackend); ok && len(selectedInterfaces) > 0 {
+		names, err := targetInterfaces(ib)
+		if err != nil {
+			return nil, false, err
+		}
+		interfaces, err := ib.Interfaces()
+		if err != nil {
+			return nil, false, err
+		}
+
+		var actual []string
+		for _, iface := range interfaces {
+			for _, name := range names {
+				if iface.Name != name {
+					continue
+				}
+				if serversDrifted(intended, iface.Servers) {
+					return iface.Servers, true, nil
+				}
+				if actual == nil {
+					actual = iface.Servers
+				}
+			}
+		}
+		return actual, false, nil
+	}
+
+	actual, err := backend.Current()
+	if err != nil {
+		return nil, false, err
+	}
+	return actual, serversDrifted(intended, actual), nil
+}
+
+// serversDrifted reports whether actual no longer starts with the intended
+// servers. Servers listed after ours, such as the per-link servers
+// systemd-resolved lists after the global ones, are not drift; servers
+// replacing or preceding ours are, since resolvers try them first.
+func serversDrifted(intended, actual []string) bool {
+	if len(actual) < len(intended) {
+		return true
+	}
+
+	want := map[string]bool{}
+	for _, server := range intended {
+		want[server] = true
+	}
+	for _, server := range actual[:len(intended)] {
+		if !want[server] {
+			return true
+		}
+	}
+	return false
+}
+
+// ReapplyProvider switches back to provider after drift and makes the
+// system pick it up.
+func ReapplyProvider(provider DNSProvider) error {
+	if _, err := ApplyProvider(provider); err != nil {
+		return fmt.Errorf("re-apply failed: %w", err)
+	}
+	if err := currentBackend().Flush(); err != nil {
+		return err
+	}
+	FlushCaches()
+	return nil
+}
+
+func sameServers(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
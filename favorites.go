@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// UserState holds what the user picks in the interfaces that outlives a
+// run. Unlike backups it belongs to the user, not to the DNS target.
+type UserState struct {
+	// Favorites are the starred providers, which are listed first.
+	Favorites []string `json:"favorites"`
+	// Auto is what automatic selection may pick: every provider ("all",
+	// the default), only the "favorites", or the "others".
+	Auto string `json:"auto,omitempty"`
+}
+
+// autoModes are the values of UserState.Auto.
+var autoModes = []string{"all", "favorites", "others"}
+
+var userState UserState
+
+func userStatePath() (string, error) {
+	dir, err := userStateDir()
+	if err != nil {
+		return "", fmt.Errorf("no user state directory: %w", err)
+	}
+	return filepath.Join(dir, "dns-switcher", "state.json"), nil
+}
+
+// LoadUserState reads the user state. A missing file is an empty state.
+func LoadUserState() (UserState, error) {
+	path, err := userStatePath()
+	if err != nil {
+		return UserState{}, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return UserState{}, nil
+	}
+	if err != nil {
+		return UserState{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var state UserState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return UserState{}, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return state, nil
+}
+
+// SaveUserState writes the user state.
+func SaveUserState(state UserState) error {
+	path, err := userStatePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// IsFavorite reports whether the provider called name is starred.
+func IsFavorite(name string) bool {
+	for _, favorite := range userState.Favorites {
+		if favorite == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFavorite stars or unstars the provider called name and saves the
+// change. The list keeps its order until it is sorted again.
+func SetFavorite(name string, on bool) error {
+	state := userState
+	state.Favorites = nil
+	for _, favorite := range userState.Favorites {
+		if favorite != name {
+			state.Favorites = append(state.Favorites, favorite)
+		}
+	}
+	if on {
+		state.Favorites = append(state.Favorites, name)
+	}
+
+	if err := SaveUserState(state); err != nil {
+		return err
+	}
+	userState = state
+	return nil
+}
+
+// SetAutoMode changes what automatic selection may pick and saves it.
+func SetAutoMode(mode string) error {
+	valid := false
+	for _, m := range autoModes {
+		valid = valid || m == mode
+	}
+	if !valid {
+		return fmt.Errorf("unknown auto mode %q (one of %s)", mode, strings.Join(autoModes, ", "))
+	}
+
+	state := userState
+	state.Auto = mode
+	if err := SaveUserState(state); err != nil {
+		return err
+	}
+	userState = state
+	return nil
+}
+
+// autoMode returns UserState.Auto with its default filled in.
+func autoMode() string {
+	if userState.Auto == "" {
+		return "all"
+	}
+	return userState.Auto
+}
+
+// AutoCandidates returns the providers automatic selection may pick.
+func AutoCandidates() []DNSProvider {
+	var candidates []DNSProvider
+	for _, p := range providers {
+		switch {
+		case !testable(p):
+		case autoMode() == "favorites" && !IsFavorite(p.Name):
+		case autoMode() == "others" && IsFavorite(p.Name):
+		default:
+			candidates = append(candidates, p)
+		}
+	}
+	return candidates
+}
+
+// AutoSelect tests the providers automatic selection may pick in parallel
+// and returns the fastest one that answers, with its latency.
+func AutoSelect() (DNSProvider, error) {
+	candidates := AutoCandidates()
+	if len(candidates) == 0 {
+		return DNSProvider{}, fmt.Errorf("no providers to pick from (auto mode %q)", autoMode())
+	}
+
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(p *DNSProvider) {
+			defer wg.Done()
+			p.Latency = probeProvider(*p)
+		}(&candidates[i])
+	}
+	wg.Wait()
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return faster(candidates[i], candidates[j])
+	})
+	if candidates[0].Latency == -1 {
+		return DNSProvider{}, fmt.Errorf("none of the %d providers answered", len(candidates))
+	}
+	return candidates[0], nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// useUserState keeps the user state of the test in a temporary directory,
+// starting empty, with providers as the provider list.
+func useUserState(t *testing.T, list ...DNSProvider) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, env := range []string{"XDG_STATE_HOME", "XDG_CONFIG_HOME", "HOME", "AppData"} {
+		t.Setenv(env, dir)
+	}
+
+	savedState, savedProviders := userState, providers
+	t.Cleanup(func() { userState, providers = savedState, savedProviders })
+	userState = UserState{}
+	if len(list) > 0 {
+		providers = list
+	}
+}
+
+// reloadUserState reads the saved user state back as a new run would.
+func reloadUserState(t *testing.T) UserState {
+	t.Helper()
+	state, err := LoadUserState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return state
+}
+
+// providerNames lists the names of list in order.
+func providerNames(list []DNSProvider) []string {
+	var names []string
+	for _, p := range list {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+func TestFavoritesPersist(t *testing.T) {
+	useUserState(t)
+
+	if state := reloadUserState(t); !reflect.DeepEqual(state, UserState{}) {
+		t.Fatalf("a missing state file reads as %+v, want an empty state", state)
+	}
+
+	for _, toggle := range []struct {
+		name string
+		on   bool
+	}{
+		{"Cloudflare", true},
+		{"Quad9", true},
+		{"Cloudflare", false},
+		{"Cloudflare", true},
+		{"Quad9", true},
+		{"Nonexistent", false},
+	} {
+		if err := SetFavorite(toggle.name, toggle.on); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Starring moves a provider to the end; starring twice keeps one entry.
+	want := []string{"Cloudflare", "Quad9"}
+	if !reflect.DeepEqual(userState.Favorites, want) {
+		t.Fatalf("favorites are %v, want %v", userState.Favorites, want)
+	}
+	if state := reloadUserState(t); !reflect.DeepEqual(state.Favorites, want) {
+		t.Fatalf("saved favorites are %v, want %v", state.Favorites, want)
+	}
+	if !IsFavorite("Quad9") || IsFavorite("Google") {
+		t.Fatal("IsFavorite does not match the favorites")
+	}
+
+	path, err := userStatePath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadUserState(); err == nil || !strings.Contains(err.Error(), "invalid "+path) {
+		t.Fatalf("got %v, want an invalid state file error", err)
+	}
+}
+
+func TestFavoritesUnknownNames(t *testing.T) {
+	useUserState(t, cloudflare, quad9, resetEntry)
+
+	// A favorite whose provider was removed from the list since stays
+	// saved but is never listed or picked.
+	userState.Favorites = []string{"Removed", "Quad9"}
+	PinFavorites()
+	if names := providerNames(providers); !reflect.DeepEqual(names, []string{"Quad9", "Cloudflare", "Reset to Default"}) {
+		t.Fatalf("providers are listed as %v", names)
+	}
+	if err := SetAutoMode("favorites"); err != nil {
+		t.Fatal(err)
+	}
+	if names := providerNames(AutoCandidates()); !reflect.DeepEqual(names, []string{"Quad9"}) {
+		t.Fatalf("auto selection picks from %v, want Quad9", names)
+	}
+	if state := reloadUserState(t); !reflect.DeepEqual(state.Favorites, []string{"Removed", "Quad9"}) {
+		t.Fatalf("saved favorites are %v", state.Favorites)
+	}
+}
+
+func TestAutoSelectModes(t *testing.T) {
+	favorite := DNSProvider{Name: "Favorite", Servers: []string{"10.0.0.1"}}
+	dead := DNSProvider{Name: "Dead", Servers: []string{"10.0.0.2"}}
+	other := DNSProvider{Name: "Other", Servers: []string{"10.0.0.3"}}
+	useUserState(t, favorite, dead, other, resetEntry)
+	useStubServers(t, map[string]*stubServer{
+		"10.0.0.1": newStubServer(t, answerWith("192.0.2.1")),
+		"10.0.0.2": newStubServer(t, failWith(dnsmessage.RCodeServerFailure)),
+		"10.0.0.3": newStubServer(t, answerWith("192.0.2.1")),
+	})
+	for _, name := range []string{"Favorite", "Dead"} {
+		if err := SetFavorite(name, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		mode       string
+		candidates []string
+		pick       string
+	}{
+		{"all", []string{"Favorite", "Dead", "Other"}, ""},
+		{"favorites", []string{"Favorite", "Dead"}, "Favorite"},
+		{"others", []string{"Other"}, "Other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			if err := SetAutoMode(tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if state := reloadUserState(t); state.Auto != tt.mode {
+				t.Fatalf("saved mode is %q, want %q", state.Auto, tt.mode)
+			}
+			if names := providerNames(AutoCandidates()); !reflect.DeepEqual(names, tt.candidates) {
+				t.Fatalf("candidates are %v, want %v", names, tt.candidates)
+			}
+
+			picked, err := AutoSelect()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if picked.Name == "Dead" || (tt.pick != "" && picked.Name != tt.pick) {
+				t.Fatalf("picked %s, want %s", picked.Name, tt.pick)
+			}
+		})
+	}
+
+	if err := SetAutoMode("fastest"); err == nil || !strings.Contains(err.Error(), `unknown auto mode "fastest"`) {
+		t.Fatalf("got %v, want an unknown mode error", err)
+	}
+	if userState.Auto != "others" {
+		t.Fatalf("a rejected mode changed the mode to %q", userState.Auto)
+	}
+
+	// No candidate answers: only the dead favorite is left to pick from.
+	if err := SetFavorite("Favorite", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetAutoMode("favorites"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AutoSelect(); err == nil || !strings.Contains(err.Error(), "none of the 1 providers answered") {
+		t.Fatalf("got %v, want no provider to answer", err)
+	}
+}
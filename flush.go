@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// cacheFlusher clears the cache of one resolver. detect reports whether the
+// resolver is in use, so only caches that exist are flushed and reported; a
+// nil detect means it always is.
+type cacheFlusher struct {
+	name   string
+	detect func(run commandRunner) bool
+	flush  func(run commandRunner) error
+}
+
+// FlushResult is the outcome of flushing one cache.
+type FlushResult struct {
+	Cache string
+	Err   error
+}
+
+// FlushCaches clears every resolver cache found on the system so no stale
+// answers outlive a switch. Nothing is flushed for a sandboxed backend.
+func FlushCaches() []FlushResult {
+	if _, ok := currentBackend().(sandboxedBackend); ok {
+		return nil
+	}
+	return flushCaches(execRunner{}, cacheFlushers())
+}
+
+// cacheNames lists the resolver caches FlushCaches would clear.
+func cacheNames() []string {
+	if _, ok := currentBackend().(sandboxedBackend); ok {
+		return nil
+	}
+	var names []string
+	for _, flusher := range cacheFlushers() {
+		if flusher.detect == nil || flusher.detect(execRunner{}) {
+			names = append(names, flusher.name)
+		}
+	}
+	return names
+}
+
+func flushCaches(run commandRunner, flushers []cacheFlusher) []FlushResult {
+	var results []FlushResult
+	for _, flusher := range flushers {
+		if flusher.detect != nil && !flusher.detect(run) {
+			continue
+		}
+		// A cache whose tool is not installed is not there to flush.
+		err := flusher.flush(run)
+		if errors.Is(err, exec.ErrNotFound) {
+			continue
+		}
+		results = append(results, FlushResult{Cache: flusher.name, Err: err})
+	}
+	return results
+}
+
+// succeeds returns a detect func that runs a command and checks its exit
+// status.
+func succeeds(name string, args ...string) func(commandRunner) bool {
+	return func(run commandRunner) bool {
+		_, err := run.Run(nil, name, args...)
+		return err == nil
+	}
+}
+
+// runs returns a flush func that runs a command.
+func runs(name string, args ...string) func(commandRunner) error {
+	return func(run commandRunner) error {
+		if _, err := run.Run(nil, name, args...); err != nil {
+			return fmt.Errorf("failed to flush: %w", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,19 @@
+//go:build darwin
+
+package main
+
+// cacheFlushers lists the resolver caches of macOS: the directory service
+// cache and mDNSResponder, which clears its cache on SIGHUP.
+func cacheFlushers() []cacheFlusher {
+	return []cacheFlusher{
+		{
+			name:  "dscacheutil",
+			flush: runs("dscacheutil", "-flushcache"),
+		},
+		{
+			name:   "mDNSResponder",
+			detect: succeeds("pgrep", "-x", "mDNSResponder"),
+			flush:  runs("killall", "-HUP", "mDNSResponder"),
+		},
+	}
+}
@@ -0,0 +1,22 @@
+//go:build darwin
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCacheFlushersDarwin(t *testing.T) {
+	run := newTranscriptRunner(t, `
+$ dscacheutil -flushcache
+$ pgrep -x mDNSResponder
+143
+$ killall -HUP mDNSResponder
+`)
+	got := flushOutcome(flushCaches(run, cacheFlushers()))
+	want := []string{"dscacheutil: ok", "mDNSResponder: ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,35 @@
+//go:build linux
+
+package main
+
+// cacheFlushers lists the caching resolvers found on Linux. A root
+// directory or namespace has no resolvers of its own running.
+func cacheFlushers() []cacheFlusher {
+	if !activeTarget.isSystem() {
+		return nil
+	}
+
+	return []cacheFlusher{
+		{
+			name:   "systemd-resolved",
+			detect: succeeds("systemctl", "is-active", "--quiet", "systemd-resolved"),
+			flush:  runs("resolvectl", "flush-caches"),
+		},
+		{
+			name:   "nscd",
+			detect: succeeds("pidof", "nscd"),
+			flush:  runs("nscd", "--invalidate=hosts"),
+		},
+		{
+			// dnsmasq clears its cache on SIGHUP.
+			name:   "dnsmasq",
+			detect: succeeds("pidof", "dnsmasq"),
+			flush:  runs("pkill", "-HUP", "-x", "dnsmasq"),
+		},
+		{
+			name:   "unbound",
+			detect: succeeds("unbound-control", "status"),
+			flush:  runs("unbound-control", "flush_zone", "."),
+		},
+	}
+}
@@ -0,0 +1,34 @@
+//go:build linux
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCacheFlushersLinux(t *testing.T) {
+	run := newTranscriptRunner(t, `
+$ systemctl is-active --quiet systemd-resolved
+$ resolvectl flush-caches
+$ pidof nscd
+! exit status 1
+$ pidof dnsmasq
+812
+$ pkill -HUP -x dnsmasq
+$ unbound-control status
+! executable file not found in $PATH
+`)
+	got := flushOutcome(flushCaches(run, cacheFlushers()))
+	want := []string{"systemd-resolved: ok", "dnsmasq: ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCacheFlushersLinuxTarget(t *testing.T) {
+	useTarget(t, "")
+	if flushers := cacheFlushers(); len(flushers) != 0 {
+		t.Fatalf("a root directory has %d caches to flush, want none", len(flushers))
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// flushOutcome renders results as "cache: error" lines, "cache: ok" for
+// the caches flushed.
+func flushOutcome(results []FlushResult) []string {
+	var lines []string
+	for _, result := range results {
+		outcome := "ok"
+		if result.Err != nil {
+			outcome = result.Err.Error()
+		}
+		lines = append(lines, result.Cache+": "+outcome)
+	}
+	return lines
+}
+
+func TestFlushCaches(t *testing.T) {
+	run := newTranscriptRunner(t, `
+$ detect-absent
+! exit status 1
+$ flush-always
+$ detect-present
+$ flush-failing
+! exit status 2
+$ flush-missing
+! executable file not found in $PATH
+`)
+	flushers := []cacheFlusher{
+		{name: "absent", detect: succeeds("detect-absent"), flush: runs("never-run")},
+		{name: "always", flush: runs("flush-always")},
+		{name: "failing", detect: succeeds("detect-present"), flush: runs("flush-failing")},
+		{name: "missing", flush: runs("flush-missing")},
+	}
+
+	// Caches not in use and tools not installed are skipped; real
+	// failures are reported.
+	got := flushOutcome(flushCaches(run, flushers))
+	want := []string{"always: ok", "failing: failed to flush: flush-failing: exit status 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestFlushCachesSandboxed(t *testing.T) {
+	useFakeBackend(t)
+	if results := FlushCaches(); results != nil {
+		t.Fatalf("the fake backend flushed %q", flushOutcome(results))
+	}
+	if names := cacheNames(); names != nil {
+		t.Fatalf("the fake backend plans to flush %s", strings.Join(names, ", "))
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows
+
+package main
+
+// cacheFlushers lists the resolver cache of Windows, kept by the DNS Client
+// service.
+func cacheFlushers() []cacheFlusher {
+	return []cacheFlusher{
+		{
+			name:  "DNS Client",
+			flush: runs("C:\\Windows\\System32\\ipconfig.exe", "/flushdns"),
+		},
+	}
+}
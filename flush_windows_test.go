@@ -0,0 +1,22 @@
+//go:build windows
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCacheFlushersWindows(t *testing.T) {
+	run := newTranscriptRunner(t, `
+$ C:\Windows\System32\ipconfig.exe /flushdns
+Windows IP Configuration
+
+Successfully flushed the DNS Resolver Cache.
+`)
+	got := flushOutcome(flushCaches(run, cacheFlushers()))
+	want := []string{"DNS Client: ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -23,8 +23,29 @@ import (
 )
 
 func RunApp() {
+	if cfg, err := LoadConfig(); err == nil {
+		config = cfg
+	}
+	// The monitor reads the setting while the panel changes it, so it is
+	// kept in the state, under its lock.
+	state.autoReapply = config.Drift.AutoReapply
+
+	if state, err := LoadUserState(); err == nil {
+		userState = state
+	}
+	PinFavorites()
+
+	// Without a theme the GUI keeps its own palette rather than the one
+	// the terminal UI uses by default.
+	guiTheme := defaultDNSTheme()
+	if config.UI.Theme != "" {
+		if palette, err := LoadTheme(config.UI.Theme); err == nil {
+			guiTheme = newDNSTheme(palette)
+		}
+	}
+
 	a := app.New()
-	a.Settings().SetTheme(newDNSTheme())
+	a.Settings().SetTheme(guiTheme)
 
 	w := a.NewWindow("DNS Switcher")
 	w.Resize(fyne.NewSize(920, 620))
@@ -106,6 +127,12 @@ type AppState struct {
 	lastLatency     int
 	monitorRunning  bool
 	monitorStop     chan struct{}
+	provider        DNSProvider
+	drifted         bool
+	driftDNS        []string
+	reapplied       int
+	autoReapply     bool
+	splitErr        error
 }
 
 var state = &AppState{}
@@ -131,11 +158,11 @@ func makeSidebar(contentArea *fyne.Container, w fyne.Window) fyne.CanvasObject {
 		contentArea.Refresh()
 	})
 	btnMonitor := widget.NewButtonWithIcon("Monitor", theme.InfoIcon(), func() {
-		contentArea.Objects = []fyne.CanvasObject{makeMonitorPanel()}
+		contentArea.Objects = []fyne.CanvasObject{makeMonitorPanel(w)}
 		contentArea.Refresh()
 	})
 	btnSettings := widget.NewButtonWithIcon("Settings", theme.SettingsIcon(), func() {
-		contentArea.Objects = []fyne.CanvasObject{makeSettingsPanel()}
+		contentArea.Objects = []fyne.CanvasObject{makeSettingsPanel(w)}
 		contentArea.Refresh()
 	})
 
@@ -155,7 +182,10 @@ func makeSidebar(contentArea *fyne.Container, w fyne.Window) fyne.CanvasObject {
 			}
 		}
 		if resetProv.Name != "" {
-			UpdateResolvConf(resetProv)
+			if _, err := ApplyProvider(resetProv); err == nil {
+				_ = currentBackend().Flush()
+				FlushCaches()
+			}
 		}
 
 		dialog.ShowInformation("Disconnected", "DNS has been reset to system defaults.", w)
@@ -203,7 +233,7 @@ func makeStatusBar() fyne.CanvasObject {
 	statusLabel := canvas.NewText("  Disconnected", colorTextSecondary)
 	statusLabel.TextSize = 12
 
-	currentDNS, err := GetCurrentDNS()
+	currentDNS, err := currentBackend().Current()
 	if err == nil && len(currentDNS) > 0 {
 		statusDot.FillColor = colorConnected
 		statusLabel.Text = fmt.Sprintf("  Active: %s", currentDNS[0])
@@ -251,7 +281,7 @@ func makeServersPanel(contentArea *fyne.Container, w fyne.Window) fyne.CanvasObj
 	for i, p := range providers {
 		idx := i
 		prov := p
-		card := makeProviderCard(idx, prov, w)
+		card := makeProviderCard(idx, prov, contentArea, w)
 		cards.Add(card)
 	}
 
@@ -265,13 +295,15 @@ func makeServersPanel(contentArea *fyne.Container, w fyne.Window) fyne.CanvasObj
 	)
 }
 
-func makeProviderCard(idx int, prov DNSProvider, w fyne.Window) fyne.CanvasObject {
+func makeProviderCard(idx int, prov DNSProvider, contentArea *fyne.Container, w fyne.Window) fyne.CanvasObject {
 	name := canvas.NewText(prov.Name, colorTextPrimary)
 	name.TextSize = 15
 	name.TextStyle = fyne.TextStyle{Bold: true}
 
 	var serversStr string
-	if len(prov.Servers) == 0 {
+	if prov.Name == "Reset to Default" {
+		serversStr = "Original system settings"
+	} else if len(prov.Servers) == 0 {
 		serversStr = "Custom..."
 	} else {
 		for i, s := range prov.Servers {
@@ -318,6 +350,23 @@ func makeProviderCard(idx int, prov DNSProvider, w fyne.Window) fyne.CanvasObjec
 	}
 
 	rightSide := container.NewHBox(latencyWidget, actionBtn)
+	if testable(prov) {
+		star := "☆"
+		if IsFavorite(prov.Name) {
+			star = "★"
+		}
+		starBtn := widget.NewButton(star, func() {
+			if err := SetFavorite(prov.Name, !IsFavorite(prov.Name)); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			PinFavorites()
+			contentArea.Objects = []fyne.CanvasObject{makeServersPanel(contentArea, w)}
+			contentArea.Refresh()
+		})
+		starBtn.Importance = widget.LowImportance
+		rightSide = container.NewHBox(latencyWidget, starBtn, actionBtn)
+	}
 	row := container.NewBorder(nil, nil, nil, rightSide, info)
 
 	bg := canvas.NewRectangle(colorSurface)
@@ -337,7 +386,7 @@ func connectToProvider(idx int, w fyne.Window) {
 	prog.Show()
 
 	go func() {
-		err := UpdateResolvConf(provider)
+		results, err := ApplyProvider(provider)
 		prog.Hide()
 
 		if err != nil {
@@ -345,19 +394,34 @@ func connectToProvider(idx int, w fyne.Window) {
 			return
 		}
 
-		_ = RestartSystemdResolved()
+		_ = currentBackend().Flush()
+		FlushCaches()
+
+		var changed []string
+		for _, result := range results {
+			changed = append(changed, result.Interface)
+		}
+		interfacesNote := ""
+		if len(changed) > 0 {
+			interfacesNote = "\nInterfaces: " + strings.Join(changed, ", ")
+		}
+		routes, _ := SplitRoutes()
+		splitErr := SplitProxyStatus(routes)
 
 		state.mu.Lock()
 		state.activeProvider = provider.Name
 		state.activeDNS = provider.Servers
+		state.provider = provider
+		state.drifted = false
 		state.connected = true
+		state.splitErr = splitErr
 		state.mu.Unlock()
 
 		if provider.Name != "Reset to Default" && len(provider.Servers) > 0 {
 			success, valErr := ValidateDNS(provider.Servers)
 			if success {
 				dialog.ShowInformation("Connected",
-					fmt.Sprintf("✅ Successfully connected to %s\nDNS servers are responding.", provider.Name), w)
+					fmt.Sprintf("✅ Successfully connected to %s\nDNS servers are responding.%s", provider.Name, interfacesNote), w)
 			} else {
 				dialog.ShowInformation("Warning",
 					fmt.Sprintf("⚠️ DNS set to %s but validation issue:\n%v", provider.Name, valErr), w)
@@ -391,6 +455,10 @@ func showCustomDNSDialog(w fyne.Window) {
 			dialog.ShowError(fmt.Errorf("Invalid DNS format.\nUse: 8.8.8.8, 1.1.1.1"), w)
 			return
 		}
+		if err := validateServers(servers); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
 		customProvider := DNSProvider{
 			Name:    "Custom DNS",
 			Servers: servers,
@@ -403,7 +471,7 @@ func showCustomDNSDialog(w fyne.Window) {
 	dlg.Show()
 }
 
-func makeMonitorPanel() fyne.CanvasObject {
+func makeMonitorPanel(w fyne.Window) fyne.CanvasObject {
 	title := canvas.NewText("Monitoring Dashboard", colorTextPrimary)
 	title.TextSize = 22
 	title.TextStyle = fyne.TextStyle{Bold: true}
@@ -416,6 +484,11 @@ func makeMonitorPanel() fyne.CanvasObject {
 	success := state.monitorSuccess
 	failed := state.monitorFailed
 	latency := state.lastLatency
+	drifted := state.drifted
+	driftDNS := state.driftDNS
+	reapplied := state.reapplied
+	autoReapplyOn := state.autoReapply
+	splitErr := state.splitErr
 	state.mu.Unlock()
 
 	if !connected {
@@ -442,6 +515,50 @@ func makeMonitorPanel() fyne.CanvasObject {
 	dnsLabel := canvas.NewText(fmt.Sprintf("DNS: %s", dnsStr), colorTextSecondary)
 	dnsLabel.TextSize = 13
 
+	driftBox := container.NewVBox()
+	if drifted {
+		driftLabel := canvas.NewText(fmt.Sprintf("⚠ DNS was changed outside DNS Switcher, now: %s",
+			strings.Join(driftDNS, ", ")), colorError)
+		driftLabel.TextSize = 13
+		driftLabel.TextStyle = fyne.TextStyle{Bold: true}
+		reapplyBtn := widget.NewButtonWithIcon("Re-apply "+provName, theme.MediaReplayIcon(), func() {
+			go func() {
+				if err := reapplyProvider(); err != nil {
+					dialog.ShowError(err, w)
+				}
+			}()
+		})
+		driftBox.Add(container.NewPadded(driftLabel))
+		driftBox.Add(container.NewPadded(reapplyBtn))
+	}
+
+	autoReapply := widget.NewCheck("Re-apply automatically when DNS changes", func(on bool) {
+		state.mu.Lock()
+		state.autoReapply = on
+		state.mu.Unlock()
+	})
+	autoReapply.Checked = autoReapplyOn
+	if reapplied > 0 {
+		autoReapply.Text += fmt.Sprintf(" (re-applied %d×)", reapplied)
+	}
+
+	splitBox := container.NewVBox()
+	if routes, err := SplitRoutes(); err != nil {
+		splitLabel := canvas.NewText("Split DNS: "+err.Error(), colorError)
+		splitLabel.TextSize = 13
+		splitBox.Add(container.NewPadded(splitLabel))
+	} else {
+		for _, route := range routes {
+			splitColor := colorTextSecondary
+			if !routedByBackend(route) && splitErr != nil {
+				splitColor = colorError
+			}
+			splitLabel := canvas.NewText(fmt.Sprintf("%s → %s (%s)", route.Domain, route.Provider.Name, SplitVia(route, splitErr)), splitColor)
+			splitLabel.TextSize = 13
+			splitBox.Add(splitLabel)
+		}
+	}
+
 	uptimeCard := makeStatCard("⏱ Uptime", formatDuration(uptime), colorPrimary)
 	latencyCard := makeStatCard("📡 Latency", formatLatency(latency), latencyColor(latency))
 	successCard := makeStatCard("✅ Success", fmt.Sprintf("%d", success), colorSuccess)
@@ -470,9 +587,12 @@ func makeMonitorPanel() fyne.CanvasObject {
 		widget.NewSeparator(),
 		container.NewPadded(provLabel),
 		container.NewPadded(dnsLabel),
+		driftBox,
+		container.NewPadded(splitBox),
 		widget.NewSeparator(),
 		container.NewPadded(statsGrid),
 		container.NewPadded(container.NewCenter(refreshBtn)),
+		container.NewPadded(autoReapply),
 	)
 }
 
@@ -545,12 +665,60 @@ func startMonitor() {
 						state.monitorFailed++
 					}
 				}
+				checkDue := state.monitorUptime%driftInterval() < 2
 				state.mu.Unlock()
+
+				if checkDue {
+					checkDrift()
+				}
 			}
 		}
 	}()
 }
 
+// checkDrift compares the servers in use with the provider we set and
+// re-applies it when that is enabled in the config.
+func checkDrift() {
+	state.mu.Lock()
+	provider := state.provider
+	connected := state.connected
+	autoReapply := state.autoReapply
+	state.mu.Unlock()
+
+	if !connected || len(provider.Servers) == 0 {
+		return
+	}
+
+	actual, drifted, err := CheckDrift(provider.Servers)
+	if err != nil {
+		return
+	}
+	if drifted && autoReapply && reapplyProvider() == nil {
+		return
+	}
+
+	state.mu.Lock()
+	state.drifted = drifted
+	state.driftDNS = actual
+	state.mu.Unlock()
+}
+
+func reapplyProvider() error {
+	state.mu.Lock()
+	provider := state.provider
+	state.mu.Unlock()
+
+	if err := ReapplyProvider(provider); err != nil {
+		return err
+	}
+
+	state.mu.Lock()
+	state.drifted = false
+	state.reapplied++
+	state.mu.Unlock()
+	return nil
+}
+
 func stopMonitor() {
 	state.mu.Lock()
 	defer state.mu.Unlock()
@@ -560,7 +728,7 @@ func stopMonitor() {
 	}
 }
 
-func makeSettingsPanel() fyne.CanvasObject {
+func makeSettingsPanel(w fyne.Window) fyne.CanvasObject {
 	title := canvas.NewText("Settings", colorTextPrimary)
 	title.TextSize = 22
 	title.TextStyle = fyne.TextStyle{Bold: true}
@@ -574,6 +742,81 @@ func makeSettingsPanel() fyne.CanvasObject {
 		}()
 	})
 
+	flushBtn := widget.NewButtonWithIcon("Flush DNS Cache", theme.DeleteIcon(), func() {
+		go func() {
+			var flushed, failed []string
+			for _, result := range FlushCaches() {
+				if result.Err != nil {
+					failed = append(failed, fmt.Sprintf("%s: %v", result.Cache, result.Err))
+				} else {
+					flushed = append(flushed, result.Cache)
+				}
+			}
+			if len(failed) > 0 {
+				dialog.ShowError(fmt.Errorf("%s", strings.Join(failed, "\n")), w)
+				return
+			}
+			if len(flushed) == 0 {
+				dialog.ShowInformation("Flush DNS Cache", "No DNS caches found.", w)
+				return
+			}
+			dialog.ShowInformation("Flush DNS Cache", "Flushed: "+strings.Join(flushed, ", "), w)
+		}()
+	})
+
+	ifaceTitle := canvas.NewText("Network Interfaces", colorPrimary)
+	ifaceTitle.TextSize = 16
+	ifaceTitle.TextStyle = fyne.TextStyle{Bold: true}
+
+	ifaceHint := canvas.NewText("Nothing checked changes the active adapter only", colorTextSecondary)
+	ifaceHint.TextSize = 12
+
+	var ifaceNames []string
+	interfaces, ifaceErr := ListInterfaces()
+	for _, iface := range interfaces {
+		ifaceNames = append(ifaceNames, iface.Name)
+	}
+	ifaceChecks := widget.NewCheckGroup(ifaceNames, func(checked []string) {
+		SelectInterfaces(checked)
+	})
+	if allInterfacesSelected() {
+		ifaceChecks.Selected = ifaceNames
+	} else {
+		ifaceChecks.Selected = SelectedInterfaces()
+	}
+	if ifaceErr != nil {
+		ifaceHint.Text = ifaceErr.Error()
+	}
+
+	autoTitle := canvas.NewText("Automatic Selection", colorPrimary)
+	autoTitle.TextSize = 16
+	autoTitle.TextStyle = fyne.TextStyle{Bold: true}
+
+	autoHint := canvas.NewText("Which providers failover and \"apply auto\" may pick", colorTextSecondary)
+	autoHint.TextSize = 12
+
+	autoLabels := map[string]string{
+		"all":       "All providers",
+		"favorites": "Favorites only",
+		"others":    "All but favorites",
+	}
+	var autoOptions []string
+	for _, mode := range autoModes {
+		autoOptions = append(autoOptions, autoLabels[mode])
+	}
+	autoRadio := widget.NewRadioGroup(autoOptions, nil)
+	autoRadio.Required = true
+	autoRadio.SetSelected(autoLabels[autoMode()])
+	autoRadio.OnChanged = func(selected string) {
+		for mode, label := range autoLabels {
+			if label == selected && mode != autoMode() {
+				if err := SetAutoMode(mode); err != nil {
+					dialog.ShowError(err, w)
+				}
+			}
+		}
+	}
+
 	aboutTitle := canvas.NewText("About", colorPrimary)
 	aboutTitle.TextSize = 16
 	aboutTitle.TextStyle = fyne.TextStyle{Bold: true}
@@ -598,6 +841,11 @@ func makeSettingsPanel() fyne.CanvasObject {
 		container.NewPadded(subtitle),
 		widget.NewSeparator(),
 		container.NewPadded(retestBtn),
+		container.NewPadded(flushBtn),
+		widget.NewSeparator(),
+		container.NewPadded(container.NewVBox(ifaceTitle, ifaceHint, ifaceChecks)),
+		widget.NewSeparator(),
+		container.NewPadded(container.NewVBox(autoTitle, autoHint, autoRadio)),
 		widget.NewSeparator(),
 		container.NewPadded(aboutCard),
 	)
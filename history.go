@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// historySize is how many latency samples the monitor keeps.
+const historySize = 60
+
+// LatencyHistory is a ring buffer of the most recent latency samples in
+// milliseconds, -1 for a probe that got no answer. It is a plain value, so
+// copies of a model do not share it.
+type LatencyHistory struct {
+	samples [historySize]int
+	next    int
+	count   int
+}
+
+// Add records a sample, dropping the oldest once the buffer is full.
+func (h *LatencyHistory) Add(latency int) {
+	h.samples[h.next] = latency
+	h.next = (h.next + 1) % historySize
+	if h.count < historySize {
+		h.count++
+	}
+}
+
+// Samples returns the samples, oldest first.
+func (h LatencyHistory) Samples() []int {
+	samples := make([]int, 0, h.count)
+	start := (h.next - h.count + historySize) % historySize
+	for i := 0; i < h.count; i++ {
+		samples = append(samples, h.samples[(start+i)%historySize])
+	}
+	return samples
+}
+
+// LatencySummary sums up a LatencyHistory. The latencies only cover probes
+// that got an answer; Loss is the percentage of probes that did not.
+type LatencySummary struct {
+	Count int
+	Min   int
+	Avg   int
+	Max   int
+	P95   int
+	Loss  float64
+}
+
+// Summary computes min, average, max, 95th percentile and loss.
+func (h LatencyHistory) Summary() LatencySummary {
+	summary := LatencySummary{Count: h.count}
+	if h.count == 0 {
+		return summary
+	}
+
+	var answered []int
+	total := 0
+	for _, latency := range h.Samples() {
+		if latency < 0 {
+			continue
+		}
+		answered = append(answered, latency)
+		total += latency
+	}
+	summary.Loss = 100 * float64(h.count-len(answered)) / float64(h.count)
+	if len(answered) == 0 {
+		return summary
+	}
+
+	sort.Ints(answered)
+	summary.Min = answered[0]
+	summary.Max = answered[len(answered)-1]
+	summary.Avg = total / len(answered)
+	// Nearest rank: the smallest sample at or above 95% of them.
+	summary.P95 = answered[(95*len(answered)+99)/100-1]
+	return summary
+}
+
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws the last width samples as block characters scaled
+// between the lowest and highest of them. Failed probes are drawn as "×".
+func sparkline(samples []int, width int) string {
+	if len(samples) > width {
+		samples = samples[len(samples)-width:]
+	}
+
+	low, high := -1, -1
+	for _, latency := range samples {
+		if latency < 0 {
+			continue
+		}
+		if low < 0 || latency < low {
+			low = latency
+		}
+		if latency > high {
+			high = latency
+		}
+	}
+
+	var line strings.Builder
+	for _, latency := range samples {
+		switch {
+		case latency < 0:
+			line.WriteRune('×')
+		case high == low:
+			line.WriteRune(sparkBlocks[0])
+		default:
+			line.WriteRune(sparkBlocks[(latency-low)*(len(sparkBlocks)-1)/(high-low)])
+		}
+	}
+	return line.String()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Interface is a network interface, or a macOS network service, with the
+// DNS servers it currently uses.
+type Interface struct {
+	Name    string
+	Servers []string
+	// Active marks the interface the backend changes when none is selected.
+	Active bool
+}
+
+// InterfaceSnapshot is the DNS configuration of one interface. Domains is
+// only recorded by backends that route lookups per interface.
+type InterfaceSnapshot struct {
+	Name    string   `json:"name"`
+	Servers []string `json:"servers"`
+	DHCP    bool     `json:"dhcp,omitempty"`
+	Domains []string `json:"domains,omitempty"`
+}
+
+// interfaceBackend is implemented by backends that can configure DNS per
+// interface. Their Snapshot records every interface and their Restore puts
+// each of them back.
+type interfaceBackend interface {
+	DNSBackend
+	Interfaces() ([]Interface, error)
+	// SetInterfaceServers sets the servers of one interface. No servers
+	// returns the interface to the servers handed out by DHCP.
+	SetInterfaceServers(name string, servers []string) error
+}
+
+// InterfaceResult is the outcome of a change on one interface.
+type InterfaceResult struct {
+	Interface string
+	Err       error
+}
+
+// selectedInterfaces holds the interfaces chosen by the user. Empty leaves
+// the choice to the backend; "all" selects every interface.
+var selectedInterfaces []string
+
+// SelectInterfaces chooses the interfaces later switches apply to.
+func SelectInterfaces(names []string) {
+	selectedInterfaces = nil
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			selectedInterfaces = append(selectedInterfaces, name)
+		}
+	}
+}
+
+// SelectedInterfaces returns the current selection for display.
+func SelectedInterfaces() []string {
+	return selectedInterfaces
+}
+
+func allInterfacesSelected() bool {
+	for _, name := range selectedInterfaces {
+		if name == "all" {
+			return true
+		}
+	}
+	return false
+}
+
+// ListInterfaces returns the interfaces of the active backend, or an error
+// when it configures all of them at once.
+func ListInterfaces() ([]Interface, error) {
+	backend := currentBackend()
+	ib, ok := backend.(interfaceBackend)
+	if !ok {
+		return nil, fmt.Errorf("the %s backend configures DNS for all interfaces at once", backend.Name())
+	}
+	return ib.Interfaces()
+}
+
+// targetInterfaces resolves the selection against the interfaces ib knows.
+func targetInterfaces(ib interfaceBackend) ([]string, error) {
+	interfaces, err := ib.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	if allInterfacesSelected() {
+		var names []string
+		for _, iface := range interfaces {
+			names = append(names, iface.Name)
+		}
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no network interfaces found")
+		}
+		return names, nil
+	}
+
+	known := map[string]bool{}
+	for _, iface := range interfaces {
+		known[iface.Name] = true
+	}
+	for _, name := range selectedInterfaces {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown interface %q", name)
+		}
+	}
+	return selectedInterfaces, nil
+}
+
+// applyServers sets servers, or DHCP for none, on the selected interfaces.
+// Without a selection the backend applies them the way it always does and
+// no per-interface results are returned.
+func applyServers(backend DNSBackend, provider DNSProvider) ([]InterfaceResult, error) {
+	if len(selectedInterfaces) == 0 {
+		if len(provider.Servers) == 0 {
+			return nil, backend.Restore(Snapshot{Backend: backend.Name(), DHCP: true})
+		}
+		return nil, backend.Apply(provider)
+	}
+
+	ib, ok := backend.(interfaceBackend)
+	if !ok {
+		return nil, fmt.Errorf("the %s backend configures DNS for all interfaces at once; drop the interface selection", backend.Name())
+	}
+
+	names, err := targetInterfaces(ib)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []InterfaceResult
+	for _, name := range names {
+		results = append(results, InterfaceResult{
+			Interface: name,
+			Err:       ib.SetInterfaceServers(name, provider.Servers),
+		})
+	}
+	return results, resultsError(results)
+}
+
+// restoreInterfaces puts back the interfaces recorded in a snapshot.
+func restoreInterfaces(ib interfaceBackend, snaps []InterfaceSnapshot) error {
+	var results []InterfaceResult
+	for _, snap := range snaps {
+		servers := snap.Servers
+		if snap.DHCP {
+			servers = nil
+		}
+		results = append(results, InterfaceResult{
+			Interface: snap.Name,
+			Err:       ib.SetInterfaceServers(snap.Name, servers),
+		})
+	}
+	return resultsError(results)
+}
+
+// resultsError joins the failures among results, naming the interface of
+// each.
+func resultsError(results []InterfaceResult) error {
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", result.Interface, result.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
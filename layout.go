@@ -0,0 +1,186 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// tableLayout holds the widths of the columns of the provider table in
+// terminal cells. A servers width of 0 hides that column.
+type tableLayout struct {
+	name    int
+	servers int
+	latency int
+}
+
+const (
+	// latencyWidth fits "9999ms" and "N/A" with room to spare.
+	latencyWidth = 8
+	// minServersWidth is the narrowest servers column worth showing; below
+	// it the column is dropped.
+	minServersWidth = 16
+	// minNameWidth keeps "Provider" and short names readable.
+	minNameWidth = 12
+)
+
+// layout sizes the table to the terminal. The name column fits the longest
+// name when there is room, the servers column takes what is left and is
+// dropped on narrow terminals. Before the size is known the table keeps
+// its classic 20/40/8 layout.
+func (m model) layout() tableLayout {
+	if m.termWidth <= 0 {
+		return tableLayout{name: 20, servers: 40, latency: latencyWidth}
+	}
+
+	name, servers := minNameWidth, cellWidth("DNS Servers")
+	for _, provider := range providers {
+		width := cellWidth(provider.Name) + 2
+		if IsFavorite(provider.Name) {
+			width += 2
+		}
+		name = max(name, width)
+		servers = max(servers, cellWidth(serverCell(provider, 1<<10)))
+	}
+
+	// Borders and padding: "  │ name │ servers │ latency │"
+	room := m.termWidth - 12 - latencyWidth
+	if fitted := min(name, max(minNameWidth, room-minServersWidth)); room-fitted >= minServersWidth {
+		return tableLayout{name: fitted, servers: min(servers, room-fitted), latency: latencyWidth}
+	}
+
+	// Without servers: "  │ name │ latency │"
+	name = max(minNameWidth, min(name, m.termWidth-9-latencyWidth))
+	return tableLayout{name: name, latency: latencyWidth}
+}
+
+// serverCell renders the servers of a provider for a column width wide:
+// the first two aligned when they fit, otherwise only the first.
+func serverCell(provider DNSProvider, width int) string {
+	switch {
+	case provider.Name == "Reset to Default":
+		return "original system settings"
+	case len(provider.Servers) == 0:
+		return ""
+	case len(provider.Servers) == 1:
+		return provider.Servers[0]
+	}
+
+	both := fmt.Sprintf("%-17s %s", provider.Servers[0], provider.Servers[1])
+	if cellWidth(both) <= width {
+		return both
+	}
+	if both = provider.Servers[0] + " " + provider.Servers[1]; cellWidth(both) <= width {
+		return both
+	}
+	return provider.Servers[0]
+}
+
+// cellWidth is the number of terminal cells s takes, ignoring escape
+// sequences and counting wide characters twice.
+func cellWidth(s string) int {
+	return ansi.StringWidth(s)
+}
+
+// fitCell pads s to width cells, cutting it short with "…" when it is
+// wider.
+func fitCell(s string, width int) string {
+	if cellWidth(s) > width {
+		s = ansi.Truncate(s, width, "…")
+	}
+	return s + strings.Repeat(" ", width-cellWidth(s))
+}
+
+// fitCellRight is fitCell aligned to the right.
+func fitCellRight(s string, width int) string {
+	if cellWidth(s) > width {
+		s = ansi.Truncate(s, width, "…")
+	}
+	return strings.Repeat(" ", width-cellWidth(s)) + s
+}
+
+// wrapHelp joins help items with " • " into lines that fit width, each
+// indented by two spaces. Unknown width keeps them on one line.
+func wrapHelp(width int, items ...string) []string {
+	var lines []string
+	line := ""
+	for _, item := range items {
+		switch {
+		case line == "":
+			line = "  " + item
+		case width > 0 && cellWidth(line+" • "+item) > width:
+			lines = append(lines, line)
+			line = "  " + item
+		default:
+			line += " • " + item
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// statRow renders a row of the two column statistics box of the monitor.
+func statRow(label, value string) string {
+	if plainOutput {
+		return "  " + label + ": " + value + "\n"
+	}
+	return "  │ " + fitCell(label, 22) + " │ " + fitCell(value, 12) + " │\n"
+}
+
+// graphWidth is how many latency samples fit on a line.
+func (m model) graphWidth() int {
+	if m.termWidth <= 0 {
+		return historySize
+	}
+	return max(10, min(historySize, m.termWidth-4))
+}
+
+// tableTop is the screen line of the first row of the provider table.
+func (m model) tableTop() int {
+	// The header ends with a newline; the border, the column names and
+	// the separator follow, or only the column names in plain output.
+	if plainOutput {
+		return strings.Count(m.selectHeader(), "\n") + 1
+	}
+	return strings.Count(m.selectHeader(), "\n") + 3
+}
+
+// updateMouse scrolls the lists with the wheel. On the provider table a
+// click selects a row and a click on the selected row opens it like enter.
+func (m model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch msg.Button {
+	case tea.MouseButtonWheelUp:
+		return m.Update(tea.KeyMsg{Type: tea.KeyUp})
+	case tea.MouseButtonWheelDown:
+		return m.Update(tea.KeyMsg{Type: tea.KeyDown})
+	case tea.MouseButtonLeft:
+	default:
+		return m, nil
+	}
+
+	if msg.Action != tea.MouseActionPress || m.screen != screenSelect ||
+		m.backupMode || m.ifaceMode || m.inputMode {
+		return m, nil
+	}
+
+	row := msg.Y - m.tableTop()
+	if row < 0 || row >= m.visibleRows() {
+		return m, nil
+	}
+	row += m.scrollOffset
+	if row >= len(m.rows()) {
+		return m, nil
+	}
+
+	if row == m.cursor {
+		return m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	}
+	m.cursor = row
+	return m.adjustScroll(), nil
+}
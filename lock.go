@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// waitForLock makes a second instance queue behind the one holding the
+// lock instead of failing. It is set by --wait.
+var waitForLock bool
+
+// LockedError is returned when another instance holds the lock.
+type LockedError struct {
+	PID int
+}
+
+func (e *LockedError) Error() string {
+	holder := "another dns-switcher"
+	if e.PID > 0 {
+		holder = fmt.Sprintf("another dns-switcher (PID %d)", e.PID)
+	}
+	return holder + " is changing the DNS configuration; try again or pass --wait"
+}
+
+// lockPath is the system-wide lock file. A sandboxed backend changes
+// nothing on the system, so it locks in its own state directory.
+func lockPath() string {
+	if _, ok := currentBackend().(sandboxedBackend); ok {
+		return filepath.Join(stateDir(), "dns-switcher.lock")
+	}
+	return systemLockPath()
+}
+
+// withLock runs fn while holding the lock, so two instances never
+// interleave backups and writes. It is not reentrant: the lock is taken
+// per open file, so a withLock inside fn, for example through
+// ApplyProvider, finds it held by this very process and fails, or with
+// --wait blocks forever. Callers holding it use the unlocked variants.
+func withLock(fn func() error) error {
+	path := lockPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	defer file.Close()
+
+	locked, err := lockFile(file, waitForLock)
+	if err != nil {
+		return fmt.Errorf("failed to lock %s: %w", path, err)
+	}
+	if !locked {
+		return &LockedError{PID: lockHolder(file)}
+	}
+	defer unlockFile(file)
+
+	// Record the holder for the message a second instance prints.
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	return fn()
+}
+
+func lockHolder(file *os.File) int {
+	data := make([]byte, 32)
+	n, _ := file.ReadAt(data, 0)
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data[:n])))
+	return pid
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestLockHeld(t *testing.T) {
+	useFakeBackend(t)
+
+	err := withLock(func() error {
+		return withLock(func() error {
+			t.Fatal("the lock was taken twice")
+			return nil
+		})
+	})
+	var locked *LockedError
+	if !errors.As(err, &locked) || locked.PID != os.Getpid() {
+		t.Fatalf("got %v, want the lock to be held by PID %d", err, os.Getpid())
+	}
+
+	// The lock is free again once fn returns.
+	if err := withLock(func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLockWait(t *testing.T) {
+	useFakeBackend(t)
+	saved := waitForLock
+	t.Cleanup(func() { waitForLock = saved })
+	waitForLock = true
+
+	held, release := make(chan struct{}), make(chan struct{})
+	go func() {
+		_ = withLock(func() error {
+			close(held)
+			<-release
+			return nil
+		})
+	}()
+	<-held
+
+	done := make(chan error)
+	go func() {
+		done <- withLock(func() error { return nil })
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("withLock returned %v while the lock was held", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("withLock did not return after the lock was released")
+	}
+}
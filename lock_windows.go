@@ -0,0 +1,35 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockRegion is the byte locked in the lock file. Windows locks are
+// mandatory, so it lies past the PID a waiting instance reads.
+var lockRegion = windows.Overlapped{Offset: 4096}
+
+// lockFile takes an exclusive lock on lockRegion of file. Without wait it
+// reports false instead of blocking when another process holds it.
+func lockFile(file *os.File, wait bool) (bool, error) {
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK)
+	if !wait {
+		flags |= windows.LOCKFILE_FAIL_IMMEDIATELY
+	}
+
+	region := lockRegion
+	err := windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, 1, 0, &region)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(file *os.File) {
+	region := lockRegion
+	_ = windows.UnlockFileEx(windows.Handle(file.Fd()), 0, 1, 0, &region)
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// typeHTTPS is the HTTPS record type of RFC 9460, which dnsmessage does not
+// know.
+const typeHTTPS dnsmessage.Type = 65
+
+// lookupTypes are the record types a lookup can ask for.
+var lookupTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"HTTPS": typeHTTPS,
+}
+
+// lookupTypeNames lists lookupTypes in the order they are offered.
+var lookupTypeNames = []string{"A", "AAAA", "CNAME", "MX", "TXT", "HTTPS"}
+
+// lookupTimeout is how long each server gets to answer a lookup.
+const lookupTimeout = 3 * time.Second
+
+var rcodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// LookupResult is the answer of one provider to a lookup. A lookup that
+// got no response has Err set; one still running has neither Err nor
+// RCode.
+type LookupResult struct {
+	Provider DNSProvider
+	// Server is the server of the provider that answered.
+	Server string
+	RCode  string
+	// Answers are the records of the answer section. Records of another
+	// type than the one asked for, like the CNAME leading to an A record,
+	// start with their type and come first, in the order given; the
+	// others are sorted.
+	Answers []string
+	// TTL is the lowest TTL of the answers.
+	TTL     uint32
+	Latency int
+	Err     error
+	// Differs is set when the answer is not the one most providers gave.
+	Differs bool
+}
+
+// parseLookupType returns the record type called name, in any case.
+func parseLookupType(name string) (dnsmessage.Type, error) {
+	qtype, ok := lookupTypes[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("unsupported record type %q (supported: %s)", name, strings.Join(lookupTypeNames, ", "))
+	}
+	return qtype, nil
+}
+
+func lookupTypeName(qtype dnsmessage.Type) string {
+	for name, t := range lookupTypes {
+		if t == qtype {
+			return name
+		}
+	}
+	return "TYPE" + strconv.Itoa(int(qtype))
+}
+
+// lookupName turns a domain typed by the user into the name to query.
+func lookupName(domain string) (dnsmessage.Name, error) {
+	domain = strings.Trim(strings.TrimSpace(domain), ".")
+	if domain == "" {
+		return dnsmessage.Name{}, fmt.Errorf("no domain given")
+	}
+	if strings.ContainsAny(domain, " \t") {
+		return dnsmessage.Name{}, fmt.Errorf("invalid domain %q", domain)
+	}
+	name, err := dnsmessage.NewName(domain + ".")
+	if err != nil {
+		return dnsmessage.Name{}, fmt.Errorf("invalid domain %q: %w", domain, err)
+	}
+	return name, nil
+}
+
+// lookupProviders returns the providers a lookup asks.
+func lookupProviders() []DNSProvider {
+	var targets []DNSProvider
+	for _, provider := range providers {
+		if testable(provider) {
+			targets = append(targets, provider)
+		}
+	}
+	return targets
+}
+
+// LookupAll asks every provider for the records of type qtype of domain in
+// parallel, and marks the answers that differ from the majority. It
+// reports whether there was a majority.
+func LookupAll(domain string, qtype dnsmessage.Type) ([]LookupResult, bool, error) {
+	name, err := lookupName(domain)
+	if err != nil {
+		return nil, false, err
+	}
+
+	targets := lookupProviders()
+	results := make([]LookupResult, len(targets))
+	var wg sync.WaitGroup
+	for i, provider := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = LookupProvider(provider, name, qtype)
+		}()
+	}
+	wg.Wait()
+
+	return results, markDissent(results), nil
+}
+
+// LookupProvider asks the servers of provider in turn until one answers.
+// Truncated answers are asked again over TCP.
+func LookupProvider(provider DNSProvider, name dnsmessage.Name, qtype dnsmessage.Type) LookupResult {
+	result := LookupResult{Provider: provider, Latency: -1}
+
+	query, err := lookupQuery(name, qtype)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	for _, server := range provider.Servers {
+		address := net.JoinHostPort(server, "53")
+		start := time.Now()
+		response, err := exchangeUDP(address, query, lookupTimeout)
+		if err == nil && truncated(response) {
+			response, err = exchangeTCP(address, query, lookupTimeout)
+		}
+		latency := int(time.Since(start).Milliseconds())
+		if err != nil {
+			result.Err = fmt.Errorf("%s: %w", server, err)
+			continue
+		}
+
+		rcode, answers, ttl, err := parseLookupResponse(response, qtype)
+		if err != nil {
+			result.Err = fmt.Errorf("%s: %w", server, err)
+			continue
+		}
+		return LookupResult{
+			Provider: provider,
+			Server:   server,
+			RCode:    rcode,
+			Answers:  answers,
+			TTL:      ttl,
+			Latency:  latency,
+		}
+	}
+	return result
+}
+
+// lookupQuery builds a recursive query for name, advertising EDNS so large
+// answers fit in a datagram.
+func lookupQuery(name dnsmessage.Name, qtype dnsmessage.Type) ([]byte, error) {
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: uint16(rand.IntN(1 << 16)), RecursionDesired: true})
+	builder.EnableCompression()
+	if err := builder.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := builder.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := builder.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := builder.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return builder.Finish()
+}
+
+func truncated(response []byte) bool {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	return err == nil && header.Truncated
+}
+
+// parseLookupResponse returns the rcode, the answers and their lowest TTL.
+// Records of types a lookup cannot ask for are left out.
+func parseLookupResponse(response []byte, qtype dnsmessage.Type) (string, []string, uint32, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return "", nil, 0, err
+	}
+	if err := parser.SkipAllQuestions(); err != nil {
+		return "", nil, 0, err
+	}
+
+	rcode, ok := rcodeNames[header.RCode]
+	if !ok {
+		rcode = "RCODE" + strconv.Itoa(int(header.RCode))
+	}
+
+	var chain, answers []string
+	var ttl uint32
+	for {
+		rh, err := parser.AnswerHeader()
+		if errors.Is(err, dnsmessage.ErrSectionDone) {
+			break
+		}
+		if err != nil {
+			return "", nil, 0, err
+		}
+		value, err := answerValue(&parser, rh.Type)
+		if err != nil {
+			return "", nil, 0, err
+		}
+		if value == "" {
+			continue
+		}
+		if len(chain)+len(answers) == 0 || rh.TTL < ttl {
+			ttl = rh.TTL
+		}
+		if rh.Type != qtype {
+			chain = append(chain, lookupTypeName(rh.Type)+" "+value)
+		} else {
+			answers = append(answers, value)
+		}
+	}
+
+	sort.Strings(answers)
+	return rcode, append(chain, answers...), ttl, nil
+}
+
+// answerValue reads the next answer, of type rtype, in presentation
+// format. Types a lookup cannot ask for are skipped and return "".
+func answerValue(parser *dnsmessage.Parser, rtype dnsmessage.Type) (string, error) {
+	switch rtype {
+	case dnsmessage.TypeA:
+		r, err := parser.AResource()
+		return netip.AddrFrom4(r.A).String(), err
+	case dnsmessage.TypeAAAA:
+		r, err := parser.AAAAResource()
+		return netip.AddrFrom16(r.AAAA).String(), err
+	case dnsmessage.TypeCNAME:
+		r, err := parser.CNAMEResource()
+		return r.CNAME.String(), err
+	case dnsmessage.TypeMX:
+		r, err := parser.MXResource()
+		return fmt.Sprintf("%d %s", r.Pref, r.MX), err
+	case dnsmessage.TypeTXT:
+		r, err := parser.TXTResource()
+		quoted := make([]string, len(r.TXT))
+		for i, txt := range r.TXT {
+			quoted[i] = strconv.Quote(txt)
+		}
+		return strings.Join(quoted, " "), err
+	case typeHTTPS:
+		r, err := parser.UnknownResource()
+		return formatHTTPS(r.Data), err
+	}
+	return "", parser.SkipAnswer()
+}
+
+// formatHTTPS renders the data of an HTTPS record like "1 . alpn=h2,h3".
+// Parameters without a readable value, like ech, show only their key.
+func formatHTTPS(data []byte) string {
+	if len(data) < 3 {
+		return "(malformed)"
+	}
+	parts := []string{strconv.Itoa(int(binary.BigEndian.Uint16(data)))}
+
+	// The target name is never compressed.
+	var labels []string
+	rest := data[2:]
+	for {
+		if len(rest) == 0 || len(rest) <= int(rest[0]) {
+			return "(malformed)"
+		}
+		length := int(rest[0])
+		label := rest[1 : 1+length]
+		rest = rest[1+length:]
+		if length == 0 {
+			break
+		}
+		labels = append(labels, string(label))
+	}
+	parts = append(parts, strings.Join(labels, ".")+".")
+
+	for len(rest) >= 4 {
+		key := binary.BigEndian.Uint16(rest)
+		length := int(binary.BigEndian.Uint16(rest[2:]))
+		if len(rest) < 4+length {
+			break
+		}
+		value := rest[4 : 4+length]
+		rest = rest[4+length:]
+
+		switch key {
+		case 1:
+			var alpn []string
+			for len(value) > 0 && len(value) > int(value[0]) {
+				alpn = append(alpn, string(value[1:1+value[0]]))
+				value = value[1+value[0]:]
+			}
+			parts = append(parts, "alpn="+strings.Join(alpn, ","))
+		case 3:
+			if len(value) == 2 {
+				parts = append(parts, "port="+strconv.Itoa(int(binary.BigEndian.Uint16(value))))
+			}
+		case 4, 6:
+			size, param := 4, "ipv4hint="
+			if key == 6 {
+				size, param = 16, "ipv6hint="
+			}
+			var hints []string
+			for ; len(value) >= size; value = value[size:] {
+				addr, _ := netip.AddrFromSlice(value[:size])
+				hints = append(hints, addr.String())
+			}
+			parts = append(parts, param+strings.Join(hints, ","))
+		case 5:
+			parts = append(parts, "ech")
+		default:
+			parts = append(parts, "key"+strconv.Itoa(int(key)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// markDissent sets Differs on the results whose answer is not the one most
+// providers gave, and reports whether there is such an answer. Failed and
+// running lookups take no part.
+func markDissent(results []LookupResult) bool {
+	counts := map[string]int{}
+	for _, result := range results {
+		if result.RCode != "" {
+			counts[result.answerKey()]++
+		}
+	}
+
+	majority, best, tie := "", 0, false
+	for key, count := range counts {
+		switch {
+		case count > best:
+			majority, best, tie = key, count, false
+		case count == best:
+			tie = true
+		}
+	}
+
+	for i := range results {
+		results[i].Differs = !tie && results[i].RCode != "" && results[i].answerKey() != majority
+	}
+	return best > 0 && !tie
+}
+
+func (r LookupResult) answerKey() string {
+	return r.RCode + "\n" + strings.Join(r.Answers, "\n")
+}
@@ -0,0 +1,324 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// lookupMsg carries the answer of one provider to the lookup numbered id.
+// Answers to an earlier lookup are dropped.
+type lookupMsg struct {
+	id     int
+	index  int
+	result LookupResult
+}
+
+func lookupCmd(id, index int, provider DNSProvider, name dnsmessage.Name, qtype dnsmessage.Type) tea.Cmd {
+	return func() tea.Msg {
+		return lookupMsg{id: id, index: index, result: LookupProvider(provider, name, qtype)}
+	}
+}
+
+// openLookup shows the lookup screen with the domain field focused.
+func (m model) openLookup() model {
+	m.screen = screenLookup
+	m.lookupEditing = true
+	m.lookupError = ""
+	return m
+}
+
+// startLookup asks every provider for the domain being looked up. The grid
+// fills in as the answers come.
+func (m model) startLookup() (model, tea.Cmd) {
+	name, err := lookupName(m.lookupDomain)
+	if err != nil {
+		m.lookupError = err.Error()
+		m.lookupEditing = true
+		return m, nil
+	}
+	qtype := lookupTypes[lookupTypeNames[m.lookupType]]
+
+	m.lookupEditing = false
+	m.lookupError = ""
+	m.lookupID++
+	m.lookupOffset = 0
+	m.lookupMajority = false
+	m.lookupResults = nil
+	m.lookupPending = 0
+
+	var cmds []tea.Cmd
+	for i, provider := range lookupProviders() {
+		m.lookupResults = append(m.lookupResults, LookupResult{Provider: provider, Latency: -1})
+		m.lookupPending++
+		cmds = append(cmds, lookupCmd(m.lookupID, i, provider, name, qtype))
+	}
+
+	var spin tea.Cmd
+	m, spin = m.spin()
+	return m, tea.Batch(append(cmds, spin)...)
+}
+
+// lookupAnswered records the answer of a provider and works out the
+// majority again.
+func (m model) lookupAnswered(msg lookupMsg) model {
+	if msg.id != m.lookupID || msg.index >= len(m.lookupResults) {
+		return m
+	}
+	// The results are shared with earlier versions of the model.
+	results := append([]LookupResult(nil), m.lookupResults...)
+	results[msg.index] = msg.result
+	m.lookupMajority = markDissent(results)
+	m.lookupResults = results
+	m.lookupPending--
+	return m
+}
+
+func (m model) updateLookup(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.lookupEditing {
+		switch msg.Type {
+		case tea.KeyCtrlC:
+			m.quitting = true
+			return m, tea.Quit
+
+		case tea.KeyEsc:
+			m.lookupEditing = false
+			m.lookupError = ""
+			if m.lookupResults == nil {
+				m.screen = screenSelect
+			}
+
+		case tea.KeyEnter:
+			return m.startLookup()
+
+		case tea.KeyTab:
+			m.lookupType = (m.lookupType + 1) % len(lookupTypeNames)
+
+		case tea.KeyBackspace:
+			if domain := []rune(m.lookupDomain); len(domain) > 0 {
+				m.lookupDomain = string(domain[:len(domain)-1])
+				m.lookupError = ""
+			}
+
+		case tea.KeyRunes:
+			m.lookupDomain += string(msg.Runes)
+			m.lookupError = ""
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "backspace":
+		m.screen = screenSelect
+
+	case "/", "e":
+		m.lookupEditing = true
+
+	case "t", "tab":
+		m.lookupType = (m.lookupType + 1) % len(lookupTypeNames)
+		return m.startLookup()
+
+	case "r":
+		return m.startLookup()
+
+	case "up", "k":
+		if m.lookupOffset > 0 {
+			m.lookupOffset--
+		}
+
+	case "down", "j":
+		if m.lookupOffset < len(m.lookupGrid())-1-m.lookupRows() {
+			m.lookupOffset++
+		}
+	}
+	return m, nil
+}
+
+// lookupGrid renders the results grid; the first line is its header.
+func (m model) lookupGrid() []string {
+	answerWidth := 0
+	if m.termWidth > 0 {
+		nameWidth := minNameWidth
+		for _, result := range m.lookupResults {
+			nameWidth = max(nameWidth, cellWidth(result.Provider.Name))
+		}
+		answerWidth = max(10, m.termWidth-min(nameWidth, 24)-30)
+	}
+	return lookupLines(m.lookupResults, answerWidth, m.spinnerFrame())
+}
+
+// lookupRows is how many rows of the grid fit on the screen.
+func (m model) lookupRows() int {
+	if m.termHeight <= 0 {
+		return len(m.lookupGrid()) - 1
+	}
+	return max(5, m.termHeight-12)
+}
+
+func (m model) lookupView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("  DNS Lookup") + "\n\n")
+
+	cursor := ""
+	if m.lookupEditing {
+		cursor = "_"
+	}
+	types := make([]string, len(lookupTypeNames))
+	for i, name := range lookupTypeNames {
+		if i == m.lookupType {
+			types[i] = selectedRowStyle.Render(symbol(name, "["+name+"]"))
+		} else {
+			types[i] = helpStyle.Render(name)
+		}
+	}
+	b.WriteString("  " + headerStyle.Render("Domain:") + " " + infoStyle.Render(m.lookupDomain+cursor) + "\n")
+	b.WriteString("  " + headerStyle.Render("Type:  ") + " " + strings.Join(types, " ") + "\n\n")
+
+	if m.lookupError != "" {
+		b.WriteString(errorStyle.Render("  "+m.lookupError) + "\n\n")
+	}
+
+	if m.lookupResults != nil {
+		lines := m.lookupGrid()
+		header, grid := lines[0], lines[1:]
+		rows := m.lookupRows()
+		offset := min(m.lookupOffset, max(0, len(grid)-rows))
+		end := min(len(grid), offset+rows)
+
+		b.WriteString(header + "\n")
+		for _, line := range grid[offset:end] {
+			b.WriteString(line + "\n")
+		}
+
+		var info []string
+		if offset > 0 {
+			info = append(info, symbol("▲", "^")+" more above")
+		}
+		if end < len(grid) {
+			info = append(info, symbol("▼", "v")+" more below")
+		}
+		if summary := lookupSummary(m.lookupResults, m.lookupMajority); summary != "" {
+			info = append(info, summary)
+		}
+		if m.lookupPending > 0 {
+			info = append(info, fmt.Sprintf("%d still running", m.lookupPending))
+		}
+		b.WriteString("\n" + infoStyle.Render("  "+strings.Join(info, " • ")) + "\n")
+	}
+	b.WriteString("\n")
+
+	var help []string
+	if m.lookupEditing {
+		help = wrapHelp(m.termWidth, "type a domain", "tab: record type", "enter: look up", "esc: cancel")
+	} else {
+		help = wrapHelp(m.termWidth, "/ or e: change domain", "t: next record type", "r: again", "↑/↓: scroll", "esc: back", "q: quit")
+	}
+	for _, line := range help {
+		b.WriteString(helpStyle.Render(line) + "\n")
+	}
+	return b.String()
+}
+
+// lookupLines renders the results of a lookup as a grid with a row per
+// answer, marking the providers that differ from the majority. Answers are
+// cut to answerWidth cells unless it is 0; lookups still running show
+// spinner.
+func lookupLines(results []LookupResult, answerWidth int, spinner string) []string {
+	nameWidth := minNameWidth
+	for _, result := range results {
+		nameWidth = max(nameWidth, cellWidth(result.Provider.Name))
+	}
+	nameWidth = min(nameWidth, 24)
+
+	lines := []string{headerStyle.Render(fmt.Sprintf("  %s %s %s %s  %s",
+		fitCell("Provider", nameWidth), fitCell("Status", 8), fitCellRight("TTL", 6), fitCellRight("Latency", 7), "Answers"))}
+	for _, result := range results {
+		marker, nameStyle := "  ", normalRowStyle
+		if result.Differs {
+			marker, nameStyle = symbol("≠ ", "! "), warningStyle
+		}
+		name := nameStyle.Render(marker + fitCell(result.Provider.Name, nameWidth))
+
+		var answers []string
+		status, ttl, latency := "", "", ""
+		statusStyle, answerStyle := successStyle, nameStyle
+		latencyStyle := failedLatencyStyle
+		switch {
+		case result.Err != nil:
+			status, statusStyle = "failed", errorStyle
+			answers = []string{result.Err.Error()}
+			answerStyle = errorStyle
+			latency = "N/A"
+		case result.RCode == "":
+			status, statusStyle = spinner, serverStyle
+		default:
+			status = result.RCode
+			if status != "NOERROR" {
+				statusStyle = errorStyle
+			}
+			answers = result.Answers
+			if len(answers) == 0 {
+				answers = []string{"(no records)"}
+				answerStyle = helpStyle
+			} else {
+				ttl = fmt.Sprintf("%d", result.TTL)
+			}
+			latency, latencyStyle = latencyLabel(result.Latency)
+		}
+		if len(answers) == 0 {
+			answers = []string{""}
+		}
+
+		for i, answer := range answers {
+			if answerWidth > 0 {
+				answer = fitCell(answer, answerWidth)
+			}
+			if i == 0 {
+				lines = append(lines, name+" "+statusStyle.Render(fitCell(status, 8))+" "+
+					fitCellRight(ttl, 6)+" "+latencyStyle.Render(fitCellRight(latency, 7))+"  "+answerStyle.Render(answer))
+				continue
+			}
+			lines = append(lines, strings.Repeat(" ", nameWidth+2+8+6+7+5)+answerStyle.Render(answer))
+		}
+	}
+	return lines
+}
+
+// lookupSummary tells how many providers agree on the answer.
+func lookupSummary(results []LookupResult, majority bool) string {
+	agree, differ, failed := 0, 0, 0
+	for _, result := range results {
+		switch {
+		case result.Err != nil:
+			failed++
+		case result.Differs:
+			differ++
+		case result.RCode != "":
+			agree++
+		}
+	}
+
+	var parts []string
+	if majority {
+		parts = append(parts, fmt.Sprintf("%d agree", agree))
+		if differ > 0 {
+			parts = append(parts, fmt.Sprintf("%d differ", differ))
+		}
+	} else if agree > 0 {
+		parts = append(parts, "no majority answer")
+	}
+	if failed > 0 {
+		parts = append(parts, fmt.Sprintf("%d failed", failed))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,108 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// answered is the result of a provider that answered rcode with answers.
+func answered(name, rcode string, answers ...string) LookupResult {
+	return LookupResult{Provider: DNSProvider{Name: name}, RCode: rcode, Answers: answers, Latency: 10}
+}
+
+func TestLookupDissent(t *testing.T) {
+	failed := LookupResult{Provider: DNSProvider{Name: "Broken"}, Latency: -1, Err: errors.New("timeout")}
+	running := LookupResult{Provider: DNSProvider{Name: "Slow"}}
+
+	tests := []struct {
+		name     string
+		results  []LookupResult
+		majority bool
+		differs  []string
+		summary  string
+	}{
+		{
+			name:     "all agree",
+			results:  []LookupResult{answered("A", "NOERROR", "192.0.2.1"), answered("B", "NOERROR", "192.0.2.1")},
+			majority: true,
+			summary:  "2 agree",
+		},
+		{
+			name: "one differs",
+			results: []LookupResult{
+				answered("A", "NOERROR", "192.0.2.1"), answered("B", "NOERROR", "192.0.2.1"), answered("C", "NOERROR", "198.51.100.1"),
+			},
+			majority: true,
+			differs:  []string{"C"},
+			summary:  "2 agree, 1 differ",
+		},
+		{
+			name: "rcode differs",
+			results: []LookupResult{
+				answered("A", "NXDOMAIN"), answered("B", "NXDOMAIN"), answered("C", "NOERROR", "0.0.0.0"),
+			},
+			majority: true,
+			differs:  []string{"C"},
+			summary:  "2 agree, 1 differ",
+		},
+		{
+			name:    "tie",
+			results: []LookupResult{answered("A", "NOERROR", "192.0.2.1"), answered("B", "NOERROR", "198.51.100.1")},
+			summary: "no majority answer",
+		},
+		{
+			name:     "failed and running take no part",
+			results:  []LookupResult{answered("A", "NOERROR", "192.0.2.1"), failed, running},
+			majority: true,
+			summary:  "1 agree, 1 failed",
+		},
+		{
+			name:    "all failed",
+			results: []LookupResult{failed, failed},
+			summary: "2 failed",
+		},
+		{
+			name:    "nothing answered yet",
+			results: []LookupResult{running},
+			summary: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			majority := markDissent(tt.results)
+			if majority != tt.majority {
+				t.Errorf("majority is %v, want %v", majority, tt.majority)
+			}
+
+			var differs []string
+			for _, result := range tt.results {
+				if result.Differs {
+					differs = append(differs, result.Provider.Name)
+				}
+			}
+			if strings.Join(differs, ",") != strings.Join(tt.differs, ",") {
+				t.Errorf("%v differ, want %v", differs, tt.differs)
+			}
+
+			if got := lookupSummary(tt.results, majority); got != tt.summary {
+				t.Errorf("summary is %q, want %q", got, tt.summary)
+			}
+
+			// The grid marks exactly the providers that differ.
+			lines := lookupLines(tt.results, 0, "...")
+			if len(lines) != 1+len(tt.results) {
+				t.Fatalf("got %d lines, want a header and a row per result:\n%s", len(lines), strings.Join(lines, "\n"))
+			}
+			for i, result := range tt.results {
+				marked := strings.Contains(lines[1+i], symbol("≠ ", "! ")+result.Provider.Name)
+				if marked != result.Differs {
+					t.Errorf("row %q is marked %v, want %v", lines[1+i], marked, result.Differs)
+				}
+			}
+		})
+	}
+}
@@ -3,92 +3,152 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/charmbracelet/lipgloss"
-)
-
-var (
-	boxStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#6272A4"))
-
-	successStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#50FA7B"))
-
-	labelStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#BD93F9")).
-			Bold(true)
-
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF5555"))
-
-	infoStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#8BE9FD"))
+	"github.com/charmbracelet/x/term"
 )
 
+// printBox prints lines in a box sized to fit them, but no wider than the
+// terminal; longer lines are cut short. Plain output lists them under the
+// title instead.
 func printBox(title string, content []string) {
-	width := 60
+	if plainOutput {
+		fmt.Println(labelStyle.Render(title + ":"))
+		for _, line := range content {
+			fmt.Println("  " + line)
+		}
+		fmt.Println()
+		return
+	}
+
+	inner := max(54, cellWidth(title))
+	for _, line := range content {
+		inner = max(inner, cellWidth(line))
+	}
+	if width, _, err := term.GetSize(os.Stdout.Fd()); err == nil && width > 20 {
+		inner = min(inner, width-6)
+	}
 
-	fmt.Println(boxStyle.Render("  ┌" + repeatStr("─", width-4) + "┐"))
-	fmt.Println(boxStyle.Render("  │ ") + labelStyle.Render(title) + boxStyle.Render(repeatStr(" ", width-len(title)-6)+"│"))
-	fmt.Println(boxStyle.Render("  ├" + repeatStr("─", width-4) + "┤"))
+	fmt.Println(boxStyle.Render("  ┌" + strings.Repeat("─", inner+2) + "┐"))
+	fmt.Println(boxStyle.Render("  │ ") + labelStyle.Render(fitCell(title, inner)) + boxStyle.Render(" │"))
+	fmt.Println(boxStyle.Render("  ├" + strings.Repeat("─", inner+2) + "┤"))
 
 	for _, line := range content {
-		padding := width - len(stripANSI(line)) - 6
-		if padding < 0 {
-			padding = 0
-		}
-		fmt.Println(boxStyle.Render("  │ ") + line + boxStyle.Render(repeatStr(" ", padding)+"│"))
+		fmt.Println(boxStyle.Render("  │ ") + fitCell(line, inner) + boxStyle.Render(" │"))
 	}
 
-	fmt.Println(boxStyle.Render("  └" + repeatStr("─", width-4) + "┘"))
+	fmt.Println(boxStyle.Render("  └" + strings.Repeat("─", inner+2) + "┘"))
 	fmt.Println()
 }
 
-func repeatStr(s string, count int) string {
-	result := ""
-	for i := 0; i < count; i++ {
-		result += s
+func requireAdmin() bool {
+	if !currentBackend().Capabilities().NeedsAdmin || IsAdmin() {
+		return true
 	}
-	return result
+	fmt.Println(errorStyle.Render("Error: Please run this program with administrator privileges"))
+	fmt.Println(infoStyle.Render("\nLinux/macOS: sudo ./dns-switcher"))
+	fmt.Println(infoStyle.Render("Windows: Run PowerShell as Administrator, then run dns-switcher.exe"))
+	return false
 }
 
-func stripANSI(str string) string {
-	inEscape := false
-	count := 0
-	for _, r := range str {
-		if r == '\x1b' {
-			inEscape = true
-			continue
-		}
-		if inEscape {
-			if r == 'm' {
-				inEscape = false
-			}
-			continue
+// interfaceResultLines renders the outcome of a switch per interface.
+func interfaceResultLines(results []InterfaceResult) []string {
+	var lines []string
+	for _, result := range results {
+		if result.Err != nil {
+			lines = append(lines, errorStyle.Render(result.Interface+": "+result.Err.Error()))
+		} else {
+			lines = append(lines, successStyle.Render(result.Interface+": updated"))
 		}
-		count++
 	}
-	result := ""
-	for i := 0; i < count; i++ {
-		result += " "
+	return lines
+}
+
+// flushResultLines renders which resolver caches were flushed.
+func flushResultLines(results []FlushResult) []string {
+	var lines []string
+	for _, result := range results {
+		if result.Err != nil {
+			lines = append(lines, errorStyle.Render("Warning: "+result.Cache+": "+result.Err.Error()))
+		} else {
+			lines = append(lines, successStyle.Render("Flushed "+result.Cache+" cache"))
+		}
 	}
-	return result
+	return lines
 }
 
 func main() {
+	cfg, err := LoadConfig()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Warning: " + err.Error()))
+	}
+	config = cfg
+
+	state, err := LoadUserState()
+	if err != nil {
+		fmt.Println(errorStyle.Render("Warning: " + err.Error()))
+	}
+	userState = state
+	PinFavorites()
+
+	flag.Usage = printUsage
+	root := flag.String("root", "", "manage the DNS configuration of a root directory (image, chroot)")
+	netns := flag.String("netns", "", "manage the DNS configuration of an ip netns network namespace")
+	backend := flag.String("backend", "", "DNS backend to use instead of the configured one")
+	iface := flag.String("interface", "", "interfaces to change, comma separated, or \"all\"")
+	flag.BoolVar(&waitForLock, "wait", false, "wait for another instance to finish instead of failing")
+	flag.BoolVar(&assumeYes, "yes", false, "switch without asking for confirmation")
+	flag.BoolVar(&assumeYes, "y", false, "shorthand for --yes")
+	themeName := flag.String("theme", "", "color theme: "+strings.Join(ThemeNames(), ", "))
+	plain := flag.Bool("plain", false, "plain output without colors, boxes or graphs")
+	flag.Parse()
+
+	if err := setupOutput(*themeName, *plain); err != nil {
+		fmt.Println(errorStyle.Render("Warning: " + err.Error()))
+	}
+
+	if err := SetTarget(*root, *netns); err != nil {
+		fmt.Println(errorStyle.Render("Error: " + err.Error()))
+		os.Exit(2)
+	}
+
+	if *backend != "" {
+		if err := UseBackend(*backend); err != nil {
+			fmt.Println(errorStyle.Render("Error: " + err.Error()))
+			os.Exit(2)
+		}
+	}
+
+	if *iface != "" {
+		SelectInterfaces(strings.Split(*iface, ","))
+	}
+
+	if flag.NArg() > 0 {
+		os.Exit(runCLI(flag.Args()))
+	}
+
+	if !term.IsTerminal(os.Stdin.Fd()) || !term.IsTerminal(os.Stdout.Fd()) {
+		fmt.Println(errorStyle.Render("Error: the interactive UI needs a terminal; run a command instead"))
+		fmt.Println()
+		printUsage()
+		os.Exit(2)
+	}
+
 	// Check if running as root/admin
-	if !IsAdmin() {
-		fmt.Println(errorStyle.Render("Error: Please run this program with administrator privileges"))
-		fmt.Println(infoStyle.Render("\nLinux/macOS: sudo ./dns-switcher"))
-		fmt.Println(infoStyle.Render("Windows: Run PowerShell as Administrator, then run dns-switcher.exe"))
+	if !requireAdmin() {
 		os.Exit(1)
 	}
 
+	if name := targetName(); name != "system" {
+		printBox("Target", []string{infoStyle.Render(name)})
+	}
+
 	// Show current DNS before starting
-	currentDNS, err := GetCurrentDNS()
+	currentDNS, err := currentBackend().Current()
 	var dnsLines []string
 	if err != nil {
 		dnsLines = append(dnsLines, infoStyle.Render("Could not read current DNS"))
@@ -101,109 +161,10 @@ func main() {
 	}
 	printBox("Current DNS Servers", dnsLines)
 
-	// Test all DNS providers for latency
-	TestAllProviders()
-
-	// Main loop - allows changing DNS multiple times
-	for {
-		// Start the bubbletea program
-		p := tea.NewProgram(initialModel())
-		finalModel, err := p.Run()
-		if err != nil {
-			fmt.Printf(errorStyle.Render("Error: %v\n"), err)
-			os.Exit(1)
-		}
-
-		// Check if user selected a provider
-		m := finalModel.(model)
-
-		// If user quit from main menu, exit
-		if m.quitting && !m.monitorMode {
-			break
-		}
-
-		if m.selected >= 0 && !m.quitting {
-			provider := providers[m.selected]
-
-			fmt.Println(labelStyle.Render("  Selected: ") + infoStyle.Render(provider.Name))
-			fmt.Println()
-
-			// Update DNS
-			statusLines := []string{}
-			statusLines = append(statusLines, infoStyle.Render("Updating DNS configuration..."))
-
-			if err := UpdateResolvConf(provider); err != nil {
-				fmt.Println(errorStyle.Render("  Error: " + err.Error()))
-				os.Exit(1)
-			}
-			statusLines = append(statusLines, successStyle.Render("Configuration updated"))
-
-			// Restart systemd-resolved if needed
-			if err := RestartSystemdResolved(); err != nil {
-				statusLines = append(statusLines, errorStyle.Render("Warning: "+err.Error()))
-			} else {
-				statusLines = append(statusLines, successStyle.Render("Service restarted"))
-			}
-
-			printBox("Update Status", statusLines)
-
-			// Show new DNS
-			newDNS, _ := GetCurrentDNS()
-			var newDNSLines []string
-			for _, dns := range newDNS {
-				newDNSLines = append(newDNSLines, infoStyle.Render(dns))
-			}
-			printBox("New DNS Servers", newDNSLines)
-
-			// Validate DNS is working
-			if provider.Name != "Reset to Default" {
-				validationLines := []string{}
-				validationLines = append(validationLines, infoStyle.Render("Testing DNS resolution..."))
-
-				success, validationErr := ValidateDNS(provider.Servers)
-				if success {
-					validationLines = append(validationLines, successStyle.Render("All DNS servers responding"))
-				} else {
-					validationLines = append(validationLines, errorStyle.Render("Validation failed: "+validationErr.Error()))
-				}
-
-				printBox("DNS Validation", validationLines)
-			}
-
-			// Enter monitoring mode
-			fmt.Println(labelStyle.Render("\n  Entering monitoring mode...\n"))
-
-			// Create monitoring model
-			monitorModel := model{
-				monitorMode: true,
-				monitorStats: MonitorStats{
-					ProviderName:   provider.Name,
-					CurrentDNS:     provider.Servers,
-					QueriesSuccess: 0,
-					QueriesFailed:  0,
-					LastLatency:    provider.Latency,
-					Uptime:         0,
-				},
-			}
-
-			// Run monitoring mode
-			p = tea.NewProgram(monitorModel)
-			monitorResult, err := p.Run()
-			if err != nil {
-				fmt.Printf(errorStyle.Render("Error: %v\n"), err)
-				os.Exit(1)
-			}
-
-			// Check if user wants to change DNS (pressed 'c')
-			monitorFinal := monitorResult.(model)
-			if monitorFinal.monitorMode == false && !monitorFinal.quitting {
-				// User pressed 'c' - go back to selection
-				fmt.Println(labelStyle.Render("\n  Returning to DNS selection...\n"))
-				continue
-			}
-
-			// User quit from monitoring - exit
-			break
-		}
+	// One program runs selection, switching and monitoring until quit
+	p := tea.NewProgram(initialModel(), tea.WithMouseCellMotion())
+	if _, err := p.Run(); err != nil {
+		fmt.Printf(errorStyle.Render("Error: %v\n"), err)
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,54 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// netnsDialer returns a dial function that opens its sockets inside the
+// network namespace at nsPath, so DNS validation and latency tests use the
+// namespace's network. A socket stays in the namespace it was created in,
+// so the calling thread only has to switch for the dial itself.
+func netnsDialer(nsPath string) func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	return func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		runtime.LockOSThread()
+
+		orig, err := os.Open(fmt.Sprintf("/proc/self/task/%d/ns/net", unix.Gettid()))
+		if err != nil {
+			runtime.UnlockOSThread()
+			return nil, fmt.Errorf("failed to open current network namespace: %w", err)
+		}
+		defer orig.Close()
+
+		ns, err := os.Open(nsPath)
+		if err != nil {
+			runtime.UnlockOSThread()
+			return nil, fmt.Errorf("failed to open %s: %w", nsPath, err)
+		}
+		defer ns.Close()
+
+		if err := unix.Setns(int(ns.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			return nil, fmt.Errorf("failed to enter %s: %w", nsPath, err)
+		}
+
+		d := net.Dialer{Timeout: timeout}
+		conn, dialErr := d.DialContext(ctx, network, address)
+
+		// If the thread cannot be switched back it stays locked, and the
+		// runtime discards it when the goroutine exits.
+		if err := unix.Setns(int(orig.Fd()), unix.CLONE_NEWNET); err == nil {
+			runtime.UnlockOSThread()
+		}
+
+		return conn, dialErr
+	}
+}
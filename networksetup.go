@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// networksetupBackend sets the DNS servers of the active network service
+// with macOS's networksetup. It only builds commands and parses their
+// output; run executes them, so it builds and runs on any OS.
+type networksetupBackend struct {
+	run commandRunner
+}
+
+func (networksetupBackend) Name() string {
+	return "networksetup"
+}
+
+func (b networksetupBackend) Current() ([]string, error) {
+	service, err := b.activeService()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.servers(service)
+}
+
+func (b networksetupBackend) servers(service string) ([]string, error) {
+	output, err := b.run.Run(nil, "networksetup", "-getdnsservers", service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNS servers: %w", err)
+	}
+
+	return parseNetworksetupServers(string(output), service), nil
+}
+
+// parseNetworksetupServers parses "networksetup -getdnsservers". It prints
+// a sentence instead of a list when only DHCP servers are in use.
+func parseNetworksetupServers(output, service string) []string {
+	result := strings.TrimSpace(output)
+	if result == "" || result == "There aren't any DNS Servers set on "+service+"." {
+		return []string{}
+	}
+
+	var dnsServers []string
+	for _, line := range strings.Split(result, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			dnsServers = append(dnsServers, line)
+		}
+	}
+	return dnsServers
+}
+
+func (b networksetupBackend) activeService() (string, error) {
+	output, err := b.run.Run(nil, "networksetup", "-listallnetworkservices")
+	if err != nil {
+		return "", fmt.Errorf("failed to list network services: %w", err)
+	}
+
+	return parseActiveNetworkService(string(output))
+}
+
+// parseNetworkServices returns the enabled services listed by
+// "networksetup -listallnetworkservices". Disabled services are marked
+// with an asterisk, which the header line explains.
+func parseNetworkServices(output string) []string {
+	var services []string
+	for _, service := range strings.Split(output, "\n") {
+		service = strings.TrimSpace(service)
+		if service == "" || strings.HasPrefix(service, "*") || strings.HasPrefix(service, "An asterisk") {
+			continue
+		}
+		services = append(services, service)
+	}
+	return services
+}
+
+// parseActiveNetworkService picks the service to configure when none is
+// selected, preferring Wi-Fi and Ethernet.
+func parseActiveNetworkService(output string) (string, error) {
+	services := parseNetworkServices(output)
+	for _, service := range services {
+		if strings.Contains(service, "Wi-Fi") || strings.Contains(service, "Ethernet") {
+			return service, nil
+		}
+	}
+
+	if len(services) > 0 {
+		return services[0], nil
+	}
+
+	return "", fmt.Errorf("no active network service found")
+}
+
+// Interfaces lists the enabled network services with their servers.
+func (b networksetupBackend) Interfaces() ([]Interface, error) {
+	output, err := b.run.Run(nil, "networksetup", "-listallnetworkservices")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network services: %w", err)
+	}
+	active, _ := parseActiveNetworkService(string(output))
+
+	var interfaces []Interface
+	for _, service := range parseNetworkServices(string(output)) {
+		servers, err := b.servers(service)
+		if err != nil {
+			return nil, err
+		}
+		interfaces = append(interfaces, Interface{Name: service, Servers: servers, Active: service == active})
+	}
+	return interfaces, nil
+}
+
+func (b networksetupBackend) Apply(provider DNSProvider) error {
+	service, err := b.activeService()
+	if err != nil {
+		return err
+	}
+
+	return b.SetInterfaceServers(service, provider.Servers)
+}
+
+func (b networksetupBackend) Snapshot() (Snapshot, error) {
+	interfaces, err := b.Interfaces()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	// networksetup only lists manually set servers; none means DHCP.
+	snap := Snapshot{Backend: "networksetup"}
+	for _, iface := range interfaces {
+		snap.Interfaces = append(snap.Interfaces, InterfaceSnapshot{
+			Name:    iface.Name,
+			Servers: iface.Servers,
+			DHCP:    len(iface.Servers) == 0,
+		})
+		if iface.Active {
+			snap.Target = iface.Name
+			snap.Servers = iface.Servers
+			snap.DHCP = len(iface.Servers) == 0
+		}
+	}
+	if snap.Target == "" {
+		return Snapshot{}, fmt.Errorf("no active network service found")
+	}
+
+	return snap, nil
+}
+
+func (b networksetupBackend) Restore(snap Snapshot) error {
+	if snap.Backend != "networksetup" {
+		return fmt.Errorf("cannot restore a %s snapshot on macOS", snap.Backend)
+	}
+
+	if len(snap.Interfaces) > 0 {
+		return restoreInterfaces(b, snap.Interfaces)
+	}
+
+	service := snap.Target
+	if service == "" {
+		var err error
+		if service, err = b.activeService(); err != nil {
+			return err
+		}
+	}
+
+	if snap.DHCP {
+		return b.SetInterfaceServers(service, nil)
+	}
+	return b.SetInterfaceServers(service, snap.Servers)
+}
+
+func (b networksetupBackend) SetInterfaceServers(service string, servers []string) error {
+	if _, err := b.run.Run(nil, "networksetup", setDNSServersArgs(service, servers)...); err != nil {
+		return fmt.Errorf("failed to update DNS: %w", err)
+	}
+
+	return nil
+}
+
+// setDNSServersArgs builds "networksetup -setdnsservers". No servers hands
+// the service back to DHCP.
+func setDNSServersArgs(service string, servers []string) []string {
+	args := []string{"-setdnsservers", service}
+	if len(servers) == 0 {
+		return append(args, "empty")
+	}
+	return append(args, servers...)
+}
+
+func (networksetupBackend) Flush() error {
+	return nil
+}
+
+func (networksetupBackend) Capabilities() Capabilities {
+	return Capabilities{NeedsAdmin: true}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseActiveNetworkService(t *testing.T) {
+	const header = "An asterisk (*) denotes that a network service is disabled.\n"
+
+	tests := []struct {
+		name    string
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{"wi-fi over other services", header + "USB 10/100/1000 LAN\nWi-Fi\n*Thunderbolt Bridge\n", "Wi-Fi", false},
+		{"first of wi-fi and ethernet", header + "Thunderbolt Ethernet Slot 1\nWi-Fi\n", "Thunderbolt Ethernet Slot 1", false},
+		{"disabled wi-fi", header + "*Wi-Fi\niPhone USB\n", "iPhone USB", false},
+		{"first service otherwise", header + "iPhone USB\nBluetooth PAN\n", "iPhone USB", false},
+		{"all disabled", header + "*Wi-Fi\n*Ethernet\n", "", true},
+		{"empty", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseActiveNetworkService(tt.output)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("got %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetworksetupCurrent(t *testing.T) {
+	b := networksetupBackend{run: loadTranscript(t, "networksetup_current.txt")}
+
+	servers, err := b.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"1.1.1.1", "1.0.0.1"}; !reflect.DeepEqual(servers, want) {
+		t.Fatalf("got %v, want %v", servers, want)
+	}
+}
+
+func TestNetworksetupApply(t *testing.T) {
+	b := networksetupBackend{run: loadTranscript(t, "networksetup_apply.txt")}
+
+	if err := b.Apply(DNSProvider{Name: "Quad9", Servers: []string{"9.9.9.9", "149.112.112.112"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNetworksetupSnapshot(t *testing.T) {
+	b := networksetupBackend{run: loadTranscript(t, "networksetup_snapshot.txt")}
+
+	snap, err := b.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Snapshot{
+		Backend: "networksetup",
+		Target:  "Wi-Fi",
+		Servers: []string{"1.1.1.1", "1.0.0.1"},
+		Interfaces: []InterfaceSnapshot{
+			{Name: "USB 10/100/1000 LAN", Servers: []string{}, DHCP: true},
+			{Name: "Wi-Fi", Servers: []string{"1.1.1.1", "1.0.0.1"}},
+		},
+	}
+	if !reflect.DeepEqual(snap, want) {
+		t.Fatalf("got %+v, want %+v", snap, want)
+	}
+}
+
+func TestNetworksetupRestore(t *testing.T) {
+	b := networksetupBackend{run: loadTranscript(t, "networksetup_restore.txt")}
+
+	err := b.Restore(Snapshot{
+		Backend: "networksetup",
+		Target:  "Wi-Fi",
+		Interfaces: []InterfaceSnapshot{
+			{Name: "USB 10/100/1000 LAN", Servers: []string{}, DHCP: true},
+			{Name: "Wi-Fi", Servers: []string{"1.1.1.1", "1.0.0.1"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetDNSServersArgs(t *testing.T) {
+	tests := []struct {
+		service string
+		servers []string
+		want    []string
+	}{
+		{"Wi-Fi", []string{"1.1.1.1", "2606:4700:4700::1111"}, []string{"-setdnsservers", "Wi-Fi", "1.1.1.1", "2606:4700:4700::1111"}},
+		{"USB 10/100/1000 LAN", nil, []string{"-setdnsservers", "USB 10/100/1000 LAN", "empty"}},
+		{"Wi-Fi; rm -rf /", []string{"9.9.9.9"}, []string{"-setdnsservers", "Wi-Fi; rm -rf /", "9.9.9.9"}},
+	}
+
+	for _, tt := range tests {
+		if got := setDNSServersArgs(tt.service, tt.servers); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("setDNSServersArgs(%q, %v) = %q, want %q", tt.service, tt.servers, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"strings"
+)
+
+// assumeYes skips the confirmation before a switch, set by --yes.
+var assumeYes bool
+
+// ApplyPlan describes what switching to a provider changes, so it can be
+// confirmed before anything is touched. Backends that write a file fill
+// Path, Before and After; the others fill Current and Servers. With
+// interfaces selected, Interfaces holds the change of each.
+type ApplyPlan struct {
+	Backend    string
+	Path       string
+	Before     string
+	After      string
+	Current    []string
+	Servers    []string
+	Interfaces []InterfaceChange
+	// Backup is set when the current configuration is backed up first,
+	// Pristine when it is also recorded as the original one.
+	Backup   bool
+	Pristine bool
+	// Restarts lists the services reloaded to pick up the change, Caches
+	// the resolver caches flushed afterwards.
+	Restarts []string
+	Caches   []string
+}
+
+// InterfaceChange is the change of the servers of one interface. No servers
+// after the change means the ones handed out by DHCP.
+type InterfaceChange struct {
+	Name   string
+	Before []string
+	After  []string
+}
+
+// fileBackend is implemented by backends that apply a provider by writing
+// one configuration file.
+type fileBackend interface {
+	// PreviewFile returns the file Apply writes, its current content and
+	// the content it would get for provider.
+	PreviewFile(provider DNSProvider) (path, before, after string, err error)
+}
+
+// restartingBackend is implemented by backends whose Flush reloads a
+// service.
+type restartingBackend interface {
+	Restarts() []string
+}
+
+// PlanApply works out what ApplyProvider would change for provider,
+// without changing anything.
+func PlanApply(provider DNSProvider) (ApplyPlan, error) {
+	backend := currentBackend()
+	plan := ApplyPlan{Backend: backend.Name(), Backup: true}
+
+	if rb, ok := backend.(restartingBackend); ok {
+		plan.Restarts = rb.Restarts()
+	}
+	plan.Caches = cacheNames()
+
+	reset := provider.Name == "Reset to Default"
+	var pristine Snapshot
+	if reset {
+		snap, ok, err := LoadPristineSnapshot(backend.Name())
+		if err != nil {
+			return ApplyPlan{}, err
+		}
+		if ok {
+			pristine = snap
+		} else {
+			pristine = Snapshot{DHCP: true}
+		}
+	} else {
+		if err := validateServers(provider.Servers); err != nil {
+			return ApplyPlan{}, err
+		}
+		_, ok, err := LoadPristineSnapshot(backend.Name())
+		if err != nil {
+			return ApplyPlan{}, err
+		}
+		plan.Pristine = !ok
+	}
+
+	if len(selectedInterfaces) > 0 {
+		return planInterfaces(plan, backend, provider, pristine)
+	}
+
+	if fb, ok := backend.(fileBackend); ok && (!reset || pristine.Content != "" || pristine.Missing) {
+		path, before, after, err := fb.PreviewFile(provider)
+		if err != nil {
+			return ApplyPlan{}, err
+		}
+		if reset {
+			after = pristine.Content
+		}
+		plan.Path, plan.Before, plan.After = path, before, after
+		return plan, nil
+	}
+
+	current, err := backend.Current()
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+	plan.Current = current
+	plan.Servers = provider.Servers
+	if reset {
+		plan.Servers = pristine.Servers
+		if pristine.DHCP {
+			plan.Servers = nil
+		}
+	}
+	return plan, nil
+}
+
+// planInterfaces fills in the change of every selected interface. A reset
+// returns each to the servers recorded for it, or to DHCP.
+func planInterfaces(plan ApplyPlan, backend DNSBackend, provider DNSProvider, pristine Snapshot) (ApplyPlan, error) {
+	ib, ok := backend.(interfaceBackend)
+	if !ok {
+		// applyServers reports this when the switch is attempted.
+		return plan, nil
+	}
+
+	names, err := targetInterfaces(ib)
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+	interfaces, err := ib.Interfaces()
+	if err != nil {
+		return ApplyPlan{}, err
+	}
+
+	current := map[string][]string{}
+	for _, iface := range interfaces {
+		current[iface.Name] = iface.Servers
+	}
+	recorded := map[string][]string{}
+	for _, snap := range pristine.Interfaces {
+		if !snap.DHCP {
+			recorded[snap.Name] = snap.Servers
+		}
+	}
+
+	for _, name := range names {
+		after := provider.Servers
+		if provider.Name == "Reset to Default" {
+			after = recorded[name]
+		}
+		plan.Interfaces = append(plan.Interfaces, InterfaceChange{Name: name, Before: current[name], After: after})
+	}
+	return plan, nil
+}
+
+// diffLines compares two files line by line. Every line of the result
+// starts with "  " when both have it, "- " when only before has it and
+// "+ " when only after has it.
+func diffLines(before, after string) []string {
+	a := splitLines(before)
+	b := splitLines(after)
+
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, "  "+a[i])
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, "- "+a[i])
+			i++
+		default:
+			lines = append(lines, "+ "+b[j])
+			j++
+		}
+	}
+	return lines
+}
+
+func splitLines(content string) []string {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return nil
+	}
+	return strings.Split(content, "\n")
+}
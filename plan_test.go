@@ -0,0 +1,85 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after string
+		want          []string
+	}{
+		{"empty", "", "", nil},
+		{"identical", "a\nb\n", "a\nb\n", []string{"  a", "  b"}},
+		{"pure insert", "", "a\nb\n", []string{"+ a", "+ b"}},
+		{"pure delete", "a\nb\n", "", []string{"- a", "- b"}},
+		{
+			name:   "interleaved",
+			before: "# old\nnameserver 1.1.1.1\nnameserver 1.0.0.1\noptions edns0\n",
+			after:  "# new\nnameserver 9.9.9.9\nnameserver 1.0.0.1\noptions edns0\nsearch lan\n",
+			want: []string{
+				"- # old", "- nameserver 1.1.1.1", "+ # new", "+ nameserver 9.9.9.9",
+				"  nameserver 1.0.0.1", "  options edns0", "+ search lan",
+			},
+		},
+		{"missing final newline", "a\nb", "a\nb\n", []string{"  a", "  b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.before, tt.after); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanApplyFake(t *testing.T) {
+	f := useFakeBackend(t)
+
+	// The first switch records the original configuration; nothing is
+	// changed by planning it.
+	plan, err := PlanApply(cloudflare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ApplyPlan{Backend: "fake", Current: []string{"192.168.1.1"}, Servers: cloudflare.Servers, Backup: true, Pristine: true}
+	if !reflect.DeepEqual(plan, want) {
+		t.Fatalf("got %+v, want %+v", plan, want)
+	}
+	checkServers(t, f, map[string][]string{"eth0": {"192.168.1.1"}, "wlan0": {"192.168.0.1"}})
+	if backups, _ := ListBackups(); len(backups) != 0 {
+		t.Fatalf("planning made %d backups", len(backups))
+	}
+
+	if _, err := ApplyProvider(cloudflare); err != nil {
+		t.Fatal(err)
+	}
+	if plan, err = PlanApply(quad9); err != nil || plan.Pristine || !reflect.DeepEqual(plan.Current, cloudflare.Servers) {
+		t.Fatalf("got %+v (%v), want a switch from %v without recording it", plan, err, cloudflare.Servers)
+	}
+
+	// A reset goes back to the recorded servers.
+	if plan, err = PlanApply(resetEntry); err != nil || !reflect.DeepEqual(plan.Servers, []string{"192.168.1.1"}) {
+		t.Fatalf("got %+v (%v), want a reset to 192.168.1.1", plan, err)
+	}
+
+	SelectInterfaces([]string{"all"})
+	plan, err = PlanApply(quad9)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantInterfaces := []InterfaceChange{
+		{Name: "eth0", Before: cloudflare.Servers, After: quad9.Servers},
+		{Name: "wlan0", Before: []string{"192.168.0.1"}, After: quad9.Servers},
+	}
+	if !reflect.DeepEqual(plan.Interfaces, wantInterfaces) {
+		t.Fatalf("got %+v, want %+v", plan.Interfaces, wantInterfaces)
+	}
+
+	if _, err := PlanApply(DNSProvider{Name: "Bad", Servers: []string{"not-an-ip"}}); err == nil {
+		t.Fatal("an invalid server was planned")
+	}
+}
@@ -0,0 +1,58 @@
+//go:build !windows
+
+package main
+
+import "strings"
+
+// planLines renders what a switch will change: the diff of the file it
+// writes or the servers before and after, then what else happens.
+func planLines(plan ApplyPlan) []string {
+	var lines []string
+	switch {
+	case len(plan.Interfaces) > 0:
+		for _, change := range plan.Interfaces {
+			lines = append(lines, labelStyle.Render(change.Name+":")+" "+
+				errorStyle.Render(serverList(change.Before))+symbol(" → ", " -> ")+successStyle.Render(serverList(change.After)))
+		}
+	case plan.Path != "":
+		lines = append(lines, labelStyle.Render(plan.Path+":"))
+		for _, line := range diffLines(plan.Before, plan.After) {
+			switch line[0] {
+			case '+':
+				lines = append(lines, successStyle.Render(line))
+			case '-':
+				lines = append(lines, errorStyle.Render(line))
+			default:
+				lines = append(lines, helpStyle.Render(line))
+			}
+		}
+	default:
+		lines = append(lines, labelStyle.Render("Servers:")+" "+
+			errorStyle.Render(serverList(plan.Current))+symbol(" → ", " -> ")+successStyle.Render(serverList(plan.Servers)))
+	}
+	lines = append(lines, "")
+
+	if plan.Backup {
+		lines = append(lines, infoStyle.Render("Backs up the current configuration first"))
+		if plan.Pristine {
+			lines = append(lines, infoStyle.Render("Records it as the original to reset to"))
+		}
+	}
+	if len(plan.Restarts) > 0 {
+		lines = append(lines, infoStyle.Render("Reloads "+strings.Join(plan.Restarts, ", ")))
+	} else {
+		lines = append(lines, infoStyle.Render("No service is restarted"))
+	}
+	if len(plan.Caches) > 0 {
+		lines = append(lines, infoStyle.Render("Flushes caches: "+strings.Join(plan.Caches, ", ")))
+	}
+	return lines
+}
+
+// serverList joins servers for display; none means DHCP.
+func serverList(servers []string) string {
+	if len(servers) == 0 {
+		return "DHCP"
+	}
+	return strings.Join(servers, ", ")
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+)
+
+// powershellBackend sets the DNS servers of the active network adapter
+// with the DnsClient cmdlets. It only builds scripts and parses their
+// output; run executes them with the PowerShell at exe, so it builds and
+// runs on any OS.
+//
+// Adapter names are chosen by users and servers come from user input, so
+// neither is ever spliced into a script as is: names are quoted with
+// psQuote and matched literally, servers must pass validateServers, and the
+// script is handed over with -EncodedCommand so no command line parsing is
+// involved.
+type powershellBackend struct {
+	run commandRunner
+	exe string
+}
+
+func (b powershellBackend) powershell(script string) ([]byte, error) {
+	return b.run.Run(nil, b.exe, "-NoProfile", "-NonInteractive", "-EncodedCommand", encodePowershell(script))
+}
+
+// encodePowershell encodes a script for -EncodedCommand, which takes
+// base64 of UTF-16LE.
+func encodePowershell(script string) string {
+	units := utf16.Encode([]rune("$ErrorActionPreference = 'Stop'\n" + script))
+	data := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(data[2*i:], unit)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// psQuote renders s as a single-quoted PowerShell string, in which nothing
+// is expanded. PowerShell also accepts the typographic single quotes as
+// delimiters, so those are doubled along with the ASCII one.
+func psQuote(s string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('\'')
+	for _, r := range s {
+		switch r {
+		case '\'', '\u2018', '\u2019', '\u201a', '\u201b':
+			quoted.WriteRune(r)
+		}
+		quoted.WriteRune(r)
+	}
+	quoted.WriteByte('\'')
+	return quoted.String()
+}
+
+// psAdapter returns script that sets $adapter to the adapter called name.
+// -InterfaceAlias and -Name treat their argument as a wildcard pattern, so
+// the adapter is looked up with -eq instead.
+func psAdapter(name string) string {
+	return fmt.Sprintf("$adapter = Get-NetAdapter | Where-Object { $_.Name -eq %s } | Select-Object -First 1\n"+
+		"if (-not $adapter) { throw 'network adapter not found' }\n", psQuote(name))
+}
+
+func (powershellBackend) Name() string {
+	return "powershell"
+}
+
+func (b powershellBackend) Current() ([]string, error) {
+	adapter, err := b.activeAdapter()
+	if err != nil {
+		return nil, err
+	}
+
+	return b.servers(adapter)
+}
+
+// psServers returns script that lists the servers of the adapter with
+// index, IPv4 before IPv6. Windows lists the site-local fec0:0:0:ffff::1-3
+// as IPv6 servers of adapters that have none; those never answer and are
+// left out.
+func psServers(index string) string {
+	return "Get-DnsClientServerAddress -InterfaceIndex " + index + " -AddressFamily IPv4,IPv6 | Sort-Object AddressFamily | " +
+		"ForEach-Object { $_.ServerAddresses } | Where-Object { $_ -notlike 'fec0:0:0:ffff::*' }"
+}
+
+func (b powershellBackend) servers(adapter string) ([]string, error) {
+	output, err := b.powershell(psAdapter(adapter) + psServers("$adapter.ifIndex"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get DNS servers: %w", err)
+	}
+
+	return parsePowershellLines(string(output)), nil
+}
+
+// parsePowershellLines splits PowerShell output into its non-empty lines.
+func parsePowershellLines(output string) []string {
+	lines := []string{}
+	for _, line := range strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+const activeAdapterScript = "Get-NetAdapter | Where-Object {$_.Status -eq 'Up'} | Select-Object -First 1 -ExpandProperty Name"
+
+func (b powershellBackend) activeAdapter() (string, error) {
+	output, err := b.powershell(activeAdapterScript)
+	if err != nil {
+		return "", fmt.Errorf("failed to get network adapter: %w", err)
+	}
+
+	return parseActiveNetworkAdapter(string(output))
+}
+
+func parseActiveNetworkAdapter(output string) (string, error) {
+	adapter := strings.TrimSpace(output)
+	if adapter == "" {
+		return "", fmt.Errorf("no active network adapter found")
+	}
+
+	return adapter, nil
+}
+
+// adaptersScript prints a line per connected adapter with its name, its
+// servers and its static servers, separated by tabs.
+// Get-DnsClientServerAddress reports DHCP and static servers alike; the
+// NameServer registry values of IPv4 and IPv6 are only set for static ones.
+var adaptersScript = "Get-NetAdapter | Where-Object { $_.Status -eq 'Up' } | ForEach-Object {\n" +
+	"$adapter = $_\n" +
+	"$servers = @(" + psServers("$adapter.ifIndex") + ") -join ','\n" +
+	"$static = @('Tcpip', 'Tcpip6' | ForEach-Object { (Get-ItemProperty -LiteralPath ('HKLM:\\SYSTEM\\CurrentControlSet\\Services\\' + $_ + '\\Parameters\\Interfaces\\' + $adapter.InterfaceGuid) -ErrorAction SilentlyContinue).NameServer } | Where-Object { $_ }) -join ','\n" +
+	"\"{0}`t{1}`t{2}\" -f $adapter.Name, $servers, $static\n" +
+	"}"
+
+// parseAdapters parses the output of adaptersScript. The first adapter is
+// the one used when none is selected, like activeAdapterScript picks it.
+func parseAdapters(output string) []InterfaceSnapshot {
+	var adapters []InterfaceSnapshot
+	for _, line := range parsePowershellLines(output) {
+		fields := strings.Split(line, "\t")
+		for len(fields) < 3 {
+			fields = append(fields, "")
+		}
+		adapters = append(adapters, InterfaceSnapshot{
+			Name:    fields[0],
+			Servers: parseCustomDNS(fields[1]),
+			DHCP:    strings.TrimSpace(fields[2]) == "",
+		})
+	}
+	return adapters
+}
+
+func (b powershellBackend) adapters() ([]InterfaceSnapshot, error) {
+	output, err := b.powershell(adaptersScript)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network adapters: %w", err)
+	}
+
+	adapters := parseAdapters(string(output))
+	if len(adapters) == 0 {
+		return nil, fmt.Errorf("no active network adapter found")
+	}
+	return adapters, nil
+}
+
+// Interfaces lists the connected adapters with their servers.
+func (b powershellBackend) Interfaces() ([]Interface, error) {
+	adapters, err := b.adapters()
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []Interface
+	for i, adapter := range adapters {
+		interfaces = append(interfaces, Interface{Name: adapter.Name, Servers: adapter.Servers, Active: i == 0})
+	}
+	return interfaces, nil
+}
+
+func (b powershellBackend) Apply(provider DNSProvider) error {
+	adapter, err := b.activeAdapter()
+	if err != nil {
+		return err
+	}
+
+	return b.SetInterfaceServers(adapter, provider.Servers)
+}
+
+func (b powershellBackend) Snapshot() (Snapshot, error) {
+	adapters, err := b.adapters()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return Snapshot{
+		Backend:    "powershell",
+		Target:     adapters[0].Name,
+		Servers:    adapters[0].Servers,
+		DHCP:       adapters[0].DHCP,
+		Interfaces: adapters,
+	}, nil
+}
+
+func (b powershellBackend) Restore(snap Snapshot) error {
+	if snap.Backend != "powershell" {
+		return fmt.Errorf("cannot restore a %s snapshot on Windows", snap.Backend)
+	}
+
+	if len(snap.Interfaces) > 0 {
+		return restoreInterfaces(b, snap.Interfaces)
+	}
+
+	adapter := snap.Target
+	if adapter == "" {
+		var err error
+		if adapter, err = b.activeAdapter(); err != nil {
+			return err
+		}
+	}
+
+	if snap.DHCP {
+		return b.SetInterfaceServers(adapter, nil)
+	}
+	return b.SetInterfaceServers(adapter, snap.Servers)
+}
+
+func (b powershellBackend) SetInterfaceServers(adapter string, servers []string) error {
+	script, err := setDNSServersScript(adapter, servers)
+	if err != nil {
+		return err
+	}
+
+	if _, err := b.powershell(script); err != nil {
+		return fmt.Errorf("failed to update DNS: %w (make sure you are running as Administrator)", err)
+	}
+
+	return nil
+}
+
+// setDNSServersScript builds the Set-DnsClientServerAddress call. No
+// servers hands the adapter back to DHCP.
+func setDNSServersScript(adapter string, servers []string) (string, error) {
+	if len(servers) == 0 {
+		return psAdapter(adapter) + "Set-DnsClientServerAddress -InterfaceIndex $adapter.ifIndex -ResetServerAddresses", nil
+	}
+
+	if err := validateServers(servers); err != nil {
+		return "", err
+	}
+	quoted := make([]string, len(servers))
+	for i, server := range servers {
+		quoted[i] = psQuote(server)
+	}
+	return psAdapter(adapter) +
+		fmt.Sprintf("Set-DnsClientServerAddress -InterfaceIndex $adapter.ifIndex -ServerAddresses @(%s)", strings.Join(quoted, ",")), nil
+}
+
+// Flush has nothing to do: the DNS Client service picks up new servers
+// right away, and its cache is cleared by FlushCaches.
+func (powershellBackend) Flush() error {
+	return nil
+}
+
+func (powershellBackend) Capabilities() Capabilities {
+	return Capabilities{NeedsAdmin: true}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestPowershellCurrent(t *testing.T) {
+	b := powershellBackend{run: loadTranscript(t, "powershell_current.txt"), exe: "powershell.exe"}
+
+	servers, err := b.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"192.168.1.1", "fd00::1"}; !reflect.DeepEqual(servers, want) {
+		t.Fatalf("got %v, want %v", servers, want)
+	}
+}
+
+func TestParseActiveNetworkAdapter(t *testing.T) {
+	tests := []struct {
+		output  string
+		want    string
+		wantErr bool
+	}{
+		{"Ethernet 2\r\n", "Ethernet 2", false},
+		{"  Wi-Fi  \n", "Wi-Fi", false},
+		{"\r\n", "", true},
+		{"", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseActiveNetworkAdapter(tt.output)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseActiveNetworkAdapter(%q) = %q, %v; want %q, error %v", tt.output, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestPowershellApply(t *testing.T) {
+	b := powershellBackend{run: loadTranscript(t, "powershell_apply.txt"), exe: "powershell.exe"}
+
+	if err := b.Apply(DNSProvider{Name: "Quad9", Servers: []string{"9.9.9.9", "149.112.112.112"}}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPowershellSnapshot(t *testing.T) {
+	b := powershellBackend{run: loadTranscript(t, "powershell_snapshot.txt"), exe: "powershell.exe"}
+
+	snap, err := b.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Snapshot{
+		Backend: "powershell",
+		Target:  "Ethernet 2",
+		Servers: []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111"},
+		Interfaces: []InterfaceSnapshot{
+			{Name: "Ethernet 2", Servers: []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111"}},
+			{Name: "Wi-Fi", Servers: []string{"192.168.0.1"}, DHCP: true},
+		},
+	}
+	if !reflect.DeepEqual(snap, want) {
+		t.Fatalf("got %+v, want %+v", snap, want)
+	}
+}
+
+func TestPowershellRestore(t *testing.T) {
+	b := powershellBackend{run: loadTranscript(t, "powershell_restore.txt"), exe: "powershell.exe"}
+
+	err := b.Restore(Snapshot{
+		Backend: "powershell",
+		Target:  "Ethernet 2",
+		Interfaces: []InterfaceSnapshot{
+			{Name: "Ethernet 2", Servers: []string{"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111"}},
+			{Name: "Wi-Fi", Servers: []string{"192.168.0.1"}, DHCP: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPsQuote(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Wi-Fi", "'Wi-Fi'"},
+		{"", "''"},
+		{"O'Brien's LAN", "'O''Brien''s LAN'"},
+		{"Wi-Fi'; Stop-Computer; '", "'Wi-Fi''; Stop-Computer; '''"},
+		{"LAN’; Stop-Computer; ’", "'LAN’’; Stop-Computer; ’’'"},
+		{"‘LAN’", "'‘‘LAN’’'"},
+		{"\u201aLAN\u201b", "'\u201a\u201aLAN\u201b\u201b'"},
+		{"$env:USERNAME", "'$env:USERNAME'"},
+		{"$(Stop-Computer)", "'$(Stop-Computer)'"},
+		{"`whoami`", "'`whoami`'"},
+		{"LAN`'; Stop-Computer", "'LAN`''; Stop-Computer'"},
+		{"a; b; c", "'a; b; c'"},
+		{`"double" quotes`, `'"double" quotes'`},
+	}
+
+	for _, tt := range tests {
+		if got := psQuote(tt.name); got != tt.want {
+			t.Errorf("psQuote(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSetDNSServersScriptHostileAdapter(t *testing.T) {
+	tests := []struct {
+		adapter string
+		servers []string
+		want    string
+	}{
+		{
+			adapter: "Wi-Fi'; Stop-Computer; '",
+			servers: []string{"1.1.1.1", "2606:4700:4700::1111"},
+			want: "$adapter = Get-NetAdapter | Where-Object { $_.Name -eq 'Wi-Fi''; Stop-Computer; ''' } | Select-Object -First 1\n" +
+				"if (-not $adapter) { throw 'network adapter not found' }\n" +
+				"Set-DnsClientServerAddress -InterfaceIndex $adapter.ifIndex -ServerAddresses @('1.1.1.1','2606:4700:4700::1111')",
+		},
+		{
+			adapter: "LAN’ $(Stop-Computer) ‘",
+			servers: nil,
+			want: "$adapter = Get-NetAdapter | Where-Object { $_.Name -eq 'LAN’’ $(Stop-Computer) ‘‘' } | Select-Object -First 1\n" +
+				"if (-not $adapter) { throw 'network adapter not found' }\n" +
+				"Set-DnsClientServerAddress -InterfaceIndex $adapter.ifIndex -ResetServerAddresses",
+		},
+		{
+			adapter: "Ethernet*`; Remove-Item -Recurse C:\\",
+			servers: []string{"9.9.9.9"},
+			want: "$adapter = Get-NetAdapter | Where-Object { $_.Name -eq 'Ethernet*`; Remove-Item -Recurse C:\\' } | Select-Object -First 1\n" +
+				"if (-not $adapter) { throw 'network adapter not found' }\n" +
+				"Set-DnsClientServerAddress -InterfaceIndex $adapter.ifIndex -ServerAddresses @('9.9.9.9')",
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := setDNSServersScript(tt.adapter, tt.servers)
+		if err != nil {
+			t.Errorf("setDNSServersScript(%q): %v", tt.adapter, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("setDNSServersScript(%q) =\n%s\nwant:\n%s", tt.adapter, got, tt.want)
+		}
+	}
+}
+
+func TestValidateServers(t *testing.T) {
+	tests := []struct {
+		servers []string
+		wantErr string
+	}{
+		{[]string{"1.1.1.1", "2606:4700:4700::1111", "::ffff:1.2.3.4"}, ""},
+		{nil, "no DNS servers given"},
+		{[]string{"1.1.1.1", "1.1.1.1'; Stop-Computer; '"}, `invalid DNS server "1.1.1.1'; Stop-Computer; '": not an IP address`},
+		{[]string{"1.1.1.1’,’; Stop-Computer"}, `invalid DNS server "1.1.1.1’,’; Stop-Computer": not an IP address`},
+		{[]string{"$(Stop-Computer)"}, `invalid DNS server "$(Stop-Computer)": not an IP address`},
+		{[]string{"`whoami`"}, "invalid DNS server \"`whoami`\": not an IP address"},
+		{[]string{"1.1.1.1;8.8.8.8"}, `invalid DNS server "1.1.1.1;8.8.8.8": not an IP address`},
+		{[]string{"1.1.1.1 8.8.8.8"}, `invalid DNS server "1.1.1.1 8.8.8.8": not an IP address`},
+		{[]string{"1.1.1.1\nnameserver 6.6.6.6"}, `invalid DNS server "1.1.1.1\nnameserver 6.6.6.6": not an IP address`},
+		{[]string{" 1.1.1.1"}, `invalid DNS server " 1.1.1.1": not an IP address`},
+		{[]string{"fe80::1%eth0"}, `invalid DNS server "fe80::1%eth0": not an IP address`},
+		{[]string{"fe80::1%'; Stop-Computer; '"}, `invalid DNS server "fe80::1%'; Stop-Computer; '": not an IP address`},
+		{[]string{"1.1.1.1:53"}, `invalid DNS server "1.1.1.1:53": not an IP address`},
+		{[]string{"[2606:4700:4700::1111]:53"}, `invalid DNS server "[2606:4700:4700::1111]:53": not an IP address`},
+		{[]string{"dns.google"}, `invalid DNS server "dns.google": not an IP address`},
+		{[]string{""}, `invalid DNS server "": not an IP address`},
+	}
+
+	for _, tt := range tests {
+		err := validateServers(tt.servers)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("validateServers(%q): %v", tt.servers, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("validateServers(%q) = %v, want %s", tt.servers, err, tt.wantErr)
+		}
+
+		// A server rejected here never makes it into a script.
+		if len(tt.servers) > 0 {
+			if script, err := setDNSServersScript("Wi-Fi", tt.servers); err == nil || script != "" {
+				t.Errorf("setDNSServersScript accepted %q:\n%s", tt.servers, script)
+			}
+		}
+	}
+}
+
+func TestEncodePowershell(t *testing.T) {
+	script := "Get-NetAdapter | Where-Object { $_.Name -eq 'LAN’’ `$x; \U0001F680' }"
+	encoded := encodePowershell(script)
+
+	if !regexp.MustCompile(`^[A-Za-z0-9+/]+=*$`).MatchString(encoded) {
+		t.Fatalf("encoded command is not plain base64: %s", encoded)
+	}
+	if got, want := decodePowershell(encoded), "$ErrorActionPreference = 'Stop'\n"+script; got != want {
+		t.Fatalf("decoded to %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ownConfigMarkers identify configuration files written by this tool, so a
+// leftover from an earlier run is never mistaken for the original setup.
+var ownConfigMarkers = []string{
+	"# Generated by dns-switcher",
+	"# Updated on ",
+}
+
+func pristinePath(backend string) string {
+	return filepath.Join(stateDir(), "pristine-"+backend+".json")
+}
+
+func isOwnSnapshot(snap Snapshot) bool {
+	for _, marker := range ownConfigMarkers {
+		if strings.HasPrefix(snap.Content, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnsurePristineSnapshot records the original DNS configuration of the
+// active backend the first time it is about to be changed.
+func EnsurePristineSnapshot() error {
+	snap, err := currentBackend().Snapshot()
+	if err != nil {
+		return err
+	}
+
+	path := pristinePath(snap.Backend)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+	if isOwnSnapshot(snap) {
+		return nil
+	}
+
+	if err := os.MkdirAll(stateDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", stateDir(), err)
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to save snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// LoadPristineSnapshot returns the original configuration recorded for a
+// backend. The boolean is false when none was recorded.
+func LoadPristineSnapshot(backend string) (Snapshot, bool, error) {
+	data, err := os.ReadFile(pristinePath(backend))
+	if os.IsNotExist(err) {
+		return Snapshot{}, false, nil
+	}
+	if err != nil {
+		return Snapshot{}, false, fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return Snapshot{}, false, fmt.Errorf("invalid snapshot %s: %w", pristinePath(backend), err)
+	}
+
+	return snap, true, nil
+}
+
+// ResetToDefault puts back the configuration the system had before the
+// first switch. Without a recorded snapshot it falls back to the servers
+// handed out by DHCP, on the selected interfaces if any.
+func ResetToDefault() ([]InterfaceResult, error) {
+	backend := currentBackend()
+
+	reset := DNSProvider{Name: "Reset to Default"}
+	if _, err := CreateBackup(reset); err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+
+	snap, ok, err := LoadPristineSnapshot(backend.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return applyServers(backend, reset)
+	}
+
+	if err := backend.Restore(snap); err != nil {
+		return nil, err
+	}
+
+	// The system is back to its original state; the next switch records
+	// whatever is in place then.
+	_ = os.Remove(pristinePath(snap.Backend))
+	return nil, nil
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Proxy is a local DNS forwarder. The system points at it once, and
+// switching providers only swaps its upstream, which takes effect
+// immediately without touching the system configuration. Queries are
+// forwarded as they are, so EDNS options reach the upstream untouched;
+// queries over UDP are answered over UDP and queries over TCP over TCP.
+// Lookups matching a split route go to the route's provider instead.
+type Proxy struct {
+	listen  []string
+	routes  []SplitRoute
+	port    string
+	timeout time.Duration
+	log     *log.Logger
+
+	// upstream is read once per query, so a swap never affects queries
+	// already in flight.
+	upstream atomic.Pointer[DNSProvider]
+
+	mu        sync.Mutex
+	packets   []net.PacketConn
+	listeners []net.Listener
+	conns     map[net.Conn]bool
+	wg        sync.WaitGroup
+}
+
+// NewProxy returns a proxy that will listen on the given addresses and
+// forward to provider, or to the provider of the first matching route.
+func NewProxy(listen []string, provider DNSProvider, routes []SplitRoute, logger *log.Logger) *Proxy {
+	p := &Proxy{
+		listen:  listen,
+		routes:  routes,
+		port:    "53",
+		timeout: 3 * time.Second,
+		log:     logger,
+		conns:   map[net.Conn]bool{},
+	}
+	p.upstream.Store(&provider)
+	return p
+}
+
+// Upstream returns the provider queries are forwarded to.
+func (p *Proxy) Upstream() DNSProvider {
+	return *p.upstream.Load()
+}
+
+// SetUpstream swaps the provider for new queries. Queries in flight finish
+// against the provider they started with.
+func (p *Proxy) SetUpstream(provider DNSProvider) {
+	old := p.upstream.Swap(&provider)
+	p.log.Printf("upstream %s -> %s", old.Name, provider.Name)
+}
+
+// Start listens on UDP and TCP on every address. An address that cannot be
+// bound, such as ::1 on a host without IPv6, is logged and skipped; it is
+// an error only if none can be.
+func (p *Proxy) Start() error {
+	for _, addr := range p.listen {
+		packet, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			p.log.Printf("cannot listen on udp %s: %v", addr, err)
+			continue
+		}
+		listener, err := net.Listen("tcp", packet.LocalAddr().String())
+		if err != nil {
+			packet.Close()
+			p.log.Printf("cannot listen on tcp %s: %v", addr, err)
+			continue
+		}
+
+		p.packets = append(p.packets, packet)
+		p.listeners = append(p.listeners, listener)
+		p.log.Printf("listening on %s", packet.LocalAddr())
+
+		p.wg.Add(2)
+		go p.serveUDP(packet)
+		go p.serveTCP(listener)
+	}
+
+	if len(p.packets) == 0 {
+		return fmt.Errorf("failed to listen on %v", p.listen)
+	}
+	return nil
+}
+
+// Close stops listening and waits for the queries in flight to be answered.
+func (p *Proxy) Close() {
+	p.mu.Lock()
+	for _, packet := range p.packets {
+		packet.Close()
+	}
+	for _, listener := range p.listeners {
+		listener.Close()
+	}
+	// Idle TCP clients would otherwise keep their connection open until
+	// they time out; a read deadline in the past ends their wait now.
+	for conn := range p.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Proxy) serveUDP(packet net.PacketConn) {
+	defer p.wg.Done()
+
+	buf := make([]byte, 65535)
+	for {
+		n, client, err := packet.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		query := append([]byte(nil), buf[:n]...)
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			response := p.answer(query, false)
+			if response == nil {
+				return
+			}
+			if limit := udpSizeLimit(query); len(response) > limit {
+				response = truncateResponse(response)
+			}
+			packet.WriteTo(response, client)
+		}()
+	}
+}
+
+func (p *Proxy) serveTCP(listener net.Listener) {
+	defer p.wg.Done()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+
+		p.mu.Lock()
+		p.conns[conn] = true
+		p.mu.Unlock()
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.serveConn(conn)
+
+			p.mu.Lock()
+			delete(p.conns, conn)
+			p.mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// serveConn answers the queries on a TCP connection one after another
+// until the client closes it or stays idle.
+func (p *Proxy) serveConn(conn net.Conn) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+
+		response := p.answer(query, true)
+		if response == nil {
+			return
+		}
+		conn.SetWriteDeadline(time.Now().Add(p.timeout))
+		if err := writeTCPMessage(conn, response); err != nil {
+			return
+		}
+	}
+}
+
+// answer forwards query to the servers of the upstream in turn and returns
+// the first response other than SERVFAIL, or SERVFAIL if none answers.
+// Queries that cannot be parsed get no response.
+func (p *Proxy) answer(query []byte, tcp bool) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+
+	upstream := p.upstream.Load()
+	if question, err := parser.Question(); err == nil {
+		if route, ok := matchRoute(p.routes, question.Name.String()); ok {
+			upstream = &route.Provider
+		}
+	}
+	var failure []byte
+	for _, server := range upstream.Servers {
+		address := net.JoinHostPort(server, p.port)
+		var response []byte
+		if tcp {
+			response, err = exchangeTCP(address, query, p.timeout)
+		} else {
+			response, err = exchangeUDP(address, query, p.timeout)
+		}
+		if err != nil {
+			p.log.Printf("%s (%s): %v", upstream.Name, server, err)
+			continue
+		}
+		// Another server may still have the answer, for example when
+		// this one cannot reach the authoritative servers.
+		if serverFailed(response) {
+			p.log.Printf("%s (%s): SERVFAIL", upstream.Name, server)
+			failure = response
+			continue
+		}
+		return response
+	}
+
+	if failure != nil {
+		return failure
+	}
+	return serverFailure(query)
+}
+
+// serverFailed reports whether response is a SERVFAIL.
+func serverFailed(response []byte) bool {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	return err == nil && header.RCode == dnsmessage.RCodeServerFailure
+}
+
+// exchangeUDP sends query to the DNS server at address and returns its
+// response.
+func exchangeUDP(address string, query []byte, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := dialDNS(ctx, "udp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	// Skip stray datagrams that do not answer this query.
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		if n >= 12 && buf[0] == query[0] && buf[1] == query[1] {
+			return append([]byte(nil), buf[:n]...), nil
+		}
+	}
+}
+
+// exchangeTCP is exchangeUDP over TCP.
+func exchangeTCP(address string, query []byte, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	conn, err := dialDNS(ctx, "tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	if err := writeTCPMessage(conn, query); err != nil {
+		return nil, err
+	}
+	return readTCPMessage(conn)
+}
+
+// readTCPMessage reads a message with its two byte length prefix.
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	if len(msg) > 65535 {
+		return fmt.Errorf("message too long")
+	}
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+// udpSizeLimit returns the largest UDP response the client accepts: the
+// payload size of its EDNS OPT record, or 512 bytes without one.
+func udpSizeLimit(query []byte) int {
+	var parser dnsmessage.Parser
+	if _, err := parser.Start(query); err != nil {
+		return 512
+	}
+	if parser.SkipAllQuestions() != nil || parser.SkipAllAnswers() != nil || parser.SkipAllAuthorities() != nil {
+		return 512
+	}
+	for {
+		header, err := parser.AdditionalHeader()
+		if err != nil {
+			return 512
+		}
+		if header.Type == dnsmessage.TypeOPT {
+			return max(int(header.Class), 512)
+		}
+		if err := parser.SkipAdditional(); err != nil {
+			return 512
+		}
+	}
+}
+
+// truncateResponse strips a response that is too large for the client down
+// to its header, question and OPT record, and sets the TC bit so the
+// client retries over TCP.
+func truncateResponse(response []byte) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(response)
+	if err != nil {
+		return response
+	}
+	questions, err := parser.AllQuestions()
+	if err != nil {
+		return response
+	}
+	header.Truncated = true
+
+	builder := dnsmessage.NewBuilder(nil, header)
+	builder.EnableCompression()
+	builder.StartQuestions()
+	for _, q := range questions {
+		builder.Question(q)
+	}
+
+	if parser.SkipAllAnswers() == nil && parser.SkipAllAuthorities() == nil {
+		for {
+			rh, err := parser.AdditionalHeader()
+			if err != nil {
+				break
+			}
+			if rh.Type != dnsmessage.TypeOPT {
+				if parser.SkipAdditional() != nil {
+					break
+				}
+				continue
+			}
+			opt, err := parser.OPTResource()
+			if err != nil {
+				break
+			}
+			builder.StartAdditionals()
+			builder.OPTResource(rh, opt)
+			break
+		}
+	}
+
+	msg, err := builder.Finish()
+	if err != nil {
+		return response
+	}
+	return msg
+}
+
+// serverFailure builds a SERVFAIL response to query.
+func serverFailure(query []byte) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil
+	}
+	questions, _ := parser.AllQuestions()
+
+	header.Response = true
+	header.RecursionAvailable = true
+	header.RCode = dnsmessage.RCodeServerFailure
+
+	builder := dnsmessage.NewBuilder(nil, header)
+	builder.StartQuestions()
+	for _, q := range questions {
+		builder.Question(q)
+	}
+	msg, err := builder.Finish()
+	if err != nil {
+		return nil
+	}
+	return msg
+}
+
+// forwardsToItself reports whether provider includes one of the addresses
+// the proxy listens on, which would make it forward queries to itself.
+func forwardsToItself(listen []string, provider DNSProvider) bool {
+	return proxyAddress(listen, provider.Servers) != ""
+}
+
+// proxyAddress returns the first address of listen on port 53 whose host
+// is one of servers, or "" if the servers do not include the proxy.
+func proxyAddress(listen, servers []string) string {
+	for _, addr := range listen {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || port != "53" {
+			continue
+		}
+		for _, server := range servers {
+			if server == host {
+				return net.JoinHostPort(host, port)
+			}
+		}
+	}
+	return ""
+}
+
+// proxyUpstreamPath is where `proxy use` leaves the provider for a running
+// proxy to pick up.
+func proxyUpstreamPath() string {
+	return filepath.Join(stateDir(), "proxy.json")
+}
+
+// SetProxyUpstream tells a running proxy to forward to provider.
+func SetProxyUpstream(provider DNSProvider) error {
+	data, err := json.MarshalIndent(provider, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(stateDir(), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", stateDir(), err)
+	}
+	if err := writeFileAtomic(proxyUpstreamPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", proxyUpstreamPath(), err)
+	}
+	return nil
+}
+
+// ProxyUpstream reads the provider last set with SetProxyUpstream.
+func ProxyUpstream() (DNSProvider, time.Time, error) {
+	info, err := os.Stat(proxyUpstreamPath())
+	if err != nil {
+		return DNSProvider{}, time.Time{}, err
+	}
+	data, err := os.ReadFile(proxyUpstreamPath())
+	if err != nil {
+		return DNSProvider{}, time.Time{}, err
+	}
+
+	var provider DNSProvider
+	if err := json.Unmarshal(data, &provider); err != nil {
+		return DNSProvider{}, time.Time{}, fmt.Errorf("invalid %s: %w", proxyUpstreamPath(), err)
+	}
+	if err := validateServers(provider.Servers); err != nil {
+		return DNSProvider{}, time.Time{}, fmt.Errorf("invalid %s: %w", proxyUpstreamPath(), err)
+	}
+	return provider, info.ModTime(), nil
+}
+
+// RunProxy serves until ctx is done, picking up upstream changes made with
+// SetProxyUpstream within a second.
+func RunProxy(ctx context.Context, proxy *Proxy) error {
+	if err := proxy.Start(); err != nil {
+		return err
+	}
+	defer proxy.Close()
+
+	_, seen, _ := ProxyUpstream()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			proxy.log.Printf("stopping")
+			return nil
+		case <-ticker.C:
+		}
+
+		provider, modified, err := ProxyUpstream()
+		if err != nil || !modified.After(seen) {
+			if err != nil && !os.IsNotExist(err) {
+				proxy.log.Printf("%v", err)
+				seen = time.Now()
+			}
+			continue
+		}
+		seen = modified
+		proxy.SetUpstream(provider)
+	}
+}
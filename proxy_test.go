@@ -0,0 +1,426 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubServer is an upstream DNS server on loopback. handle answers each
+// query; a nil answer is never sent, as if the server were down.
+type stubServer struct {
+	packet   net.PacketConn
+	listener net.Listener
+	handle   func(query []byte) []byte
+
+	mu      sync.Mutex
+	queries []stubQuery
+}
+
+type stubQuery struct {
+	data []byte
+	tcp  bool
+}
+
+func newStubServer(t *testing.T, handle func(query []byte) []byte) *stubServer {
+	t.Helper()
+	packet, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		packet.Close()
+		t.Fatal(err)
+	}
+	s := &stubServer{packet: packet, listener: listener, handle: handle}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 65535)
+		for {
+			n, client, err := packet.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := append([]byte(nil), buf[:n]...)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if response := s.serve(query, false); response != nil {
+					packet.WriteTo(response, client)
+				}
+			}()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				conn.SetDeadline(time.Now().Add(5 * time.Second))
+				query, err := readTCPMessage(conn)
+				if err != nil {
+					return
+				}
+				if response := s.serve(query, true); response != nil {
+					writeTCPMessage(conn, response)
+				}
+			}()
+		}
+	}()
+
+	t.Cleanup(func() {
+		packet.Close()
+		listener.Close()
+		wg.Wait()
+	})
+	return s
+}
+
+func (s *stubServer) serve(query []byte, tcp bool) []byte {
+	s.mu.Lock()
+	s.queries = append(s.queries, stubQuery{data: query, tcp: tcp})
+	s.mu.Unlock()
+	return s.handle(query)
+}
+
+func (s *stubServer) received() []stubQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]stubQuery(nil), s.queries...)
+}
+
+// useStubServers sends the connections opened to the servers named by the
+// keys of stubs to those stubs instead. Other addresses are dialed as they
+// are.
+func useStubServers(t *testing.T, stubs map[string]*stubServer) {
+	t.Helper()
+	saved := dialDNS
+	t.Cleanup(func() { dialDNS = saved })
+
+	dialDNS = func(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		target := address
+		if stub, ok := stubs[host]; ok {
+			target = stub.packet.LocalAddr().String()
+			if network == "tcp" {
+				target = stub.listener.Addr().String()
+			}
+		}
+		return saved(ctx, network, target, timeout)
+	}
+}
+
+// testQuery builds an A query for name, with an EDNS payload size unless it
+// is zero.
+func testQuery(t *testing.T, name string, payload int) []byte {
+	t.Helper()
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: 0x1234, RecursionDesired: true})
+	builder.StartQuestions()
+	builder.Question(dnsmessage.Question{Name: dnsmessage.MustNewName(name), Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET})
+	if payload > 0 {
+		builder.StartAdditionals()
+		var opt dnsmessage.ResourceHeader
+		opt.SetEDNS0(payload, dnsmessage.RCodeSuccess, false)
+		builder.OPTResource(opt, dnsmessage.OPTResource{})
+	}
+	query, err := builder.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return query
+}
+
+// answerWith returns a handler answering every query with the A records of
+// addrs, echoing its OPT record.
+func answerWith(addrs ...string) func([]byte) []byte {
+	return func(query []byte) []byte {
+		return stubResponse(query, dnsmessage.RCodeSuccess, addrs)
+	}
+}
+
+func failWith(rcode dnsmessage.RCode) func([]byte) []byte {
+	return func(query []byte) []byte {
+		return stubResponse(query, rcode, nil)
+	}
+}
+
+func stubResponse(query []byte, rcode dnsmessage.RCode, addrs []string) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil
+	}
+	question, err := parser.Question()
+	if err != nil {
+		return nil
+	}
+	parser.SkipAllQuestions()
+	parser.SkipAllAnswers()
+	parser.SkipAllAuthorities()
+
+	header.Response = true
+	header.RecursionAvailable = true
+	header.RCode = rcode
+	builder := dnsmessage.NewBuilder(nil, header)
+	builder.EnableCompression()
+	builder.StartQuestions()
+	builder.Question(question)
+	builder.StartAnswers()
+	for _, addr := range addrs {
+		rh := dnsmessage.ResourceHeader{Name: question.Name, Class: dnsmessage.ClassINET, TTL: 300}
+		builder.AResource(rh, dnsmessage.AResource{A: netip.MustParseAddr(addr).As4()})
+	}
+	for {
+		rh, err := parser.AdditionalHeader()
+		if err != nil {
+			break
+		}
+		if rh.Type != dnsmessage.TypeOPT {
+			parser.SkipAdditional()
+			continue
+		}
+		opt, _ := parser.OPTResource()
+		builder.StartAdditionals()
+		builder.OPTResource(rh, opt)
+		break
+	}
+	response, _ := builder.Finish()
+	return response
+}
+
+// startTestProxy runs a proxy on loopback forwarding to upstream, giving up
+// on each upstream server after timeout.
+func startTestProxy(t *testing.T, upstream DNSProvider, routes []SplitRoute, timeout time.Duration) *Proxy {
+	t.Helper()
+	p := NewProxy([]string{"127.0.0.1:0"}, upstream, routes, log.New(io.Discard, "", 0))
+	p.timeout = timeout
+	if err := p.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *Proxy) testExchange(t *testing.T, query []byte, tcp bool) []byte {
+	t.Helper()
+	var response []byte
+	var err error
+	if tcp {
+		response, err = exchangeTCP(p.listeners[0].Addr().String(), query, 2*time.Second)
+	} else {
+		response, err = exchangeUDP(p.packets[0].LocalAddr().String(), query, 2*time.Second)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// checkAnswer fails t unless response has rcode and the A records addrs.
+func checkAnswer(t *testing.T, response []byte, rcode string, addrs ...string) {
+	t.Helper()
+	gotRCode, answers, _, err := parseLookupResponse(response, dnsmessage.TypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs = append([]string{}, addrs...)
+	sort.Strings(addrs)
+	if answers == nil {
+		answers = []string{}
+	}
+	if gotRCode != rcode || !reflect.DeepEqual(answers, addrs) {
+		t.Fatalf("got %s %v, want %s %v", gotRCode, answers, rcode, addrs)
+	}
+}
+
+func TestProxyForwards(t *testing.T) {
+	stub := newStubServer(t, answerWith("192.0.2.1"))
+	useStubServers(t, map[string]*stubServer{"10.0.0.1": stub})
+	p := startTestProxy(t, DNSProvider{Name: "Stub", Servers: []string{"10.0.0.1"}}, nil, time.Second)
+
+	for _, tcp := range []bool{false, true} {
+		query := testQuery(t, "example.com.", 1232)
+		checkAnswer(t, p.testExchange(t, query, tcp), "NOERROR", "192.0.2.1")
+
+		// Queries are forwarded as they are, over the transport they came
+		// in on.
+		received := stub.received()
+		last := received[len(received)-1]
+		if last.tcp != tcp || !reflect.DeepEqual(last.data, query) {
+			t.Fatalf("upstream got %x over tcp=%v, want %x over tcp=%v", last.data, last.tcp, query, tcp)
+		}
+	}
+}
+
+func TestProxyTruncatesLargeUDPResponses(t *testing.T) {
+	// 60 A records need about 1 KB, more than fits in 512 bytes.
+	var addrs []string
+	for i := 1; i <= 60; i++ {
+		addrs = append(addrs, netip.AddrFrom4([4]byte{192, 0, 2, byte(i)}).String())
+	}
+	stub := newStubServer(t, answerWith(addrs...))
+	useStubServers(t, map[string]*stubServer{"10.0.0.1": stub})
+	p := startTestProxy(t, DNSProvider{Name: "Stub", Servers: []string{"10.0.0.1"}}, nil, time.Second)
+
+	response := p.testExchange(t, testQuery(t, "example.com.", 0), false)
+	if !truncated(response) || len(response) > 512 {
+		t.Fatalf("got %d bytes, truncated %v; want a truncated response", len(response), truncated(response))
+	}
+	checkAnswer(t, response, "NOERROR")
+
+	// The client retries over TCP and gets everything.
+	response = p.testExchange(t, testQuery(t, "example.com.", 0), true)
+	if truncated(response) {
+		t.Fatal("TCP response is truncated")
+	}
+	checkAnswer(t, response, "NOERROR", addrs...)
+
+	// A client announcing a larger payload gets everything over UDP.
+	response = p.testExchange(t, testQuery(t, "example.com.", 4096), false)
+	if truncated(response) {
+		t.Fatal("response fitting the EDNS payload size is truncated")
+	}
+	checkAnswer(t, response, "NOERROR", addrs...)
+}
+
+func TestUDPSizeLimit(t *testing.T) {
+	tests := []struct {
+		payload int
+		want    int
+	}{
+		{0, 512},
+		{256, 512},
+		{1232, 1232},
+		{4096, 4096},
+	}
+	for _, tt := range tests {
+		if got := udpSizeLimit(testQuery(t, "example.com.", tt.payload)); got != tt.want {
+			t.Errorf("payload %d: got %d, want %d", tt.payload, got, tt.want)
+		}
+	}
+	if got := udpSizeLimit([]byte{1, 2, 3}); got != 512 {
+		t.Errorf("malformed query: got %d, want 512", got)
+	}
+}
+
+func TestTruncateResponseKeepsOPT(t *testing.T) {
+	query := testQuery(t, "example.com.", 1232)
+	response := truncateResponse(stubResponse(query, dnsmessage.RCodeSuccess, []string{"192.0.2.1", "192.0.2.2"}))
+
+	if !truncated(response) {
+		t.Fatal("TC bit not set")
+	}
+	checkAnswer(t, response, "NOERROR")
+	if got := udpSizeLimit(response); got != 1232 {
+		t.Fatalf("OPT record lost: payload size %d", got)
+	}
+}
+
+func TestProxyFallsBack(t *testing.T) {
+	tests := []struct {
+		name  string
+		first func([]byte) []byte
+	}{
+		{"servfail", failWith(dnsmessage.RCodeServerFailure)},
+		{"timeout", func([]byte) []byte { return nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			first := newStubServer(t, tt.first)
+			second := newStubServer(t, answerWith("192.0.2.2"))
+			useStubServers(t, map[string]*stubServer{"10.0.0.1": first, "10.0.0.2": second})
+			p := startTestProxy(t, DNSProvider{Name: "Stub", Servers: []string{"10.0.0.1", "10.0.0.2"}}, nil, 300*time.Millisecond)
+
+			for _, tcp := range []bool{false, true} {
+				checkAnswer(t, p.testExchange(t, testQuery(t, "example.com.", 0), tcp), "NOERROR", "192.0.2.2")
+			}
+			if len(first.received()) != 2 {
+				t.Fatalf("first upstream got %d queries, want 2", len(first.received()))
+			}
+		})
+	}
+}
+
+func TestProxyAllUpstreamsFail(t *testing.T) {
+	servfail := newStubServer(t, failWith(dnsmessage.RCodeServerFailure))
+	down := newStubServer(t, func([]byte) []byte { return nil })
+	useStubServers(t, map[string]*stubServer{"10.0.0.1": down, "10.0.0.2": servfail})
+
+	p := startTestProxy(t, DNSProvider{Name: "Down", Servers: []string{"10.0.0.1"}}, nil, 300*time.Millisecond)
+	checkAnswer(t, p.testExchange(t, testQuery(t, "example.com.", 0), false), "SERVFAIL")
+
+	p.SetUpstream(DNSProvider{Name: "Failing", Servers: []string{"10.0.0.2", "10.0.0.1"}})
+	checkAnswer(t, p.testExchange(t, testQuery(t, "example.com.", 0), false), "SERVFAIL")
+}
+
+func TestProxySetUpstream(t *testing.T) {
+	release := make(chan struct{})
+	slow := newStubServer(t, func(query []byte) []byte {
+		<-release
+		return stubResponse(query, dnsmessage.RCodeSuccess, []string{"192.0.2.1"})
+	})
+	var once sync.Once
+	t.Cleanup(func() { once.Do(func() { close(release) }) })
+	fast := newStubServer(t, answerWith("192.0.2.2"))
+	useStubServers(t, map[string]*stubServer{"10.0.0.1": slow, "10.0.0.2": fast})
+
+	p := startTestProxy(t, DNSProvider{Name: "Slow", Servers: []string{"10.0.0.1"}}, nil, 2*time.Second)
+
+	// A query is in flight at the old upstream during the swap.
+	inFlight := make(chan []byte)
+	go func() {
+		response, _ := exchangeUDP(p.packets[0].LocalAddr().String(), testQuery(t, "example.com.", 0), 3*time.Second)
+		inFlight <- response
+	}()
+	for len(slow.received()) == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p.SetUpstream(DNSProvider{Name: "Fast", Servers: []string{"10.0.0.2"}})
+	if got := p.Upstream().Name; got != "Fast" {
+		t.Fatalf("upstream is %s, want Fast", got)
+	}
+	checkAnswer(t, p.testExchange(t, testQuery(t, "example.com.", 0), false), "NOERROR", "192.0.2.2")
+	checkAnswer(t, p.testExchange(t, testQuery(t, "example.com.", 0), true), "NOERROR", "192.0.2.2")
+
+	once.Do(func() { close(release) })
+	checkAnswer(t, <-inFlight, "NOERROR", "192.0.2.1")
+	if len(slow.received()) != 1 {
+		t.Fatalf("old upstream got %d queries, want only the one in flight", len(slow.received()))
+	}
+}
+
+func TestProxySplitRoutes(t *testing.T) {
+	general := newStubServer(t, answerWith("192.0.2.1"))
+	corp := newStubServer(t, answerWith("10.1.1.1"))
+	useStubServers(t, map[string]*stubServer{"10.0.0.1": general, "10.0.0.2": corp})
+
+	routes := []SplitRoute{{Domain: "corp.example", Provider: DNSProvider{Name: "Corp", Servers: []string{"10.0.0.2"}}}}
+	p := startTestProxy(t, DNSProvider{Name: "General", Servers: []string{"10.0.0.1"}}, routes, time.Second)
+
+	checkAnswer(t, p.testExchange(t, testQuery(t, "intranet.corp.example.", 0), false), "NOERROR", "10.1.1.1")
+	checkAnswer(t, p.testExchange(t, testQuery(t, "example.com.", 0), false), "NOERROR", "192.0.2.1")
+}
@@ -0,0 +1,176 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// resolvconfDebianRecords is where Debian's resolvconf keeps the records
+// registered with "resolvconf -a".
+const resolvconfDebianRecords = "/run/resolvconf/interface"
+
+func init() {
+	registerBackend("resolvconf", 10,
+		func() bool { return activeTarget.isSystem() && resolvconfManaged() },
+		func() (DNSBackend, error) {
+			if !activeTarget.isSystem() {
+				return nil, fmt.Errorf("the resolvconf backend can only manage the running system")
+			}
+			return newResolvconf(execRunner{}, config.Resolvconf), nil
+		})
+}
+
+// resolvconf registers our servers as an interface record with resolvconf
+// or openresolv instead of writing resolv.conf, which either of them would
+// overwrite on the next update.
+type resolvconf struct {
+	run        commandRunner
+	settings   ResolvconfConfig
+	openresolv bool
+}
+
+func newResolvconf(run commandRunner, settings ResolvconfConfig) resolvconf {
+	r := resolvconf{run: run, settings: settings}
+	output, err := run.Run(nil, "resolvconf", "--version")
+	r.openresolv = err == nil && strings.Contains(string(output), "openresolv")
+	return r
+}
+
+// resolvconfManaged reports whether resolv.conf is generated by resolvconf.
+func resolvconfManaged() bool {
+	if _, err := exec.LookPath("resolvconf"); err != nil {
+		return false
+	}
+
+	if link, err := os.Readlink(resolvConfPath); err == nil && strings.Contains(link, "resolvconf") {
+		return true
+	}
+
+	content, err := os.ReadFile(resolvConfPath)
+	return err == nil && strings.Contains(string(content), "resolvconf")
+}
+
+func (r resolvconf) record() string {
+	if r.settings.Record == "" {
+		return defaultConfig().Resolvconf.Record
+	}
+	return r.settings.Record
+}
+
+// addArgs builds the "resolvconf -a" invocation. Debian's resolvconf has no
+// metric and orders records by name through interface-order, so the
+// priority flags are only passed to openresolv.
+func (r resolvconf) addArgs() []string {
+	var args []string
+	if r.openresolv {
+		args = append(args, "-m", strconv.Itoa(r.settings.Metric))
+		if r.settings.Exclusive {
+			args = append(args, "-x")
+		}
+	}
+	return append(args, "-a", r.record())
+}
+
+func (r resolvconf) Name() string {
+	return "resolvconf"
+}
+
+// Current returns the servers resolvconf put into resolv.conf, which are
+// ours only while our record is the preferred one.
+func (r resolvconf) Current() ([]string, error) {
+	return resolvConfBackend{}.Current()
+}
+
+// recordServers returns the servers of our record; empty when it is not
+// registered.
+func (r resolvconf) recordServers() ([]string, error) {
+	if r.openresolv {
+		output, err := r.run.Run(nil, "resolvconf", "-l", r.record())
+		if err != nil {
+			// openresolv fails when the record does not exist.
+			return []string{}, nil
+		}
+		return parseNameservers(string(output)), nil
+	}
+
+	content, err := os.ReadFile(filepath.Join(resolvconfDebianRecords, r.record()))
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read resolvconf record: %w", err)
+	}
+	return parseNameservers(string(content)), nil
+}
+
+func (r resolvconf) Apply(provider DNSProvider) error {
+	return r.add(provider.Servers)
+}
+
+func (r resolvconf) add(servers []string) error {
+	var content strings.Builder
+	for _, dns := range servers {
+		content.WriteString(fmt.Sprintf("nameserver %s\n", dns))
+	}
+
+	if _, err := r.run.Run([]byte(content.String()), "resolvconf", r.addArgs()...); err != nil {
+		return fmt.Errorf("failed to register %s with resolvconf: %w", r.record(), err)
+	}
+
+	return nil
+}
+
+func (r resolvconf) Snapshot() (Snapshot, error) {
+	servers, err := r.recordServers()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{Backend: "resolvconf", Target: r.record(), Servers: servers}, nil
+}
+
+// Restore registers the snapshot's servers again, or removes our record
+// when it had none. Without our record resolvconf falls back to the DHCP
+// records, which also covers DHCP snapshots.
+func (r resolvconf) Restore(snap Snapshot) error {
+	if snap.Backend != "resolvconf" {
+		return fmt.Errorf("cannot restore a %s snapshot with the resolvconf backend", snap.Backend)
+	}
+	if snap.Target != "" {
+		r.settings.Record = snap.Target
+	}
+	if snap.DHCP || len(snap.Servers) == 0 {
+		return r.remove()
+	}
+	return r.add(snap.Servers)
+}
+
+func (r resolvconf) Flush() error {
+	return reloadSystemdResolved()
+}
+
+func (r resolvconf) Restarts() []string {
+	return resolvedRestarts()
+}
+
+func (r resolvconf) Capabilities() Capabilities {
+	return Capabilities{NeedsAdmin: true, Flush: true}
+}
+
+func (r resolvconf) remove() error {
+	args := []string{"-d", r.record()}
+	if r.openresolv {
+		args = append([]string{"-f"}, args...)
+	}
+
+	if _, err := r.run.Run(nil, "resolvconf", args...); err != nil {
+		return fmt.Errorf("failed to remove %s from resolvconf: %w", r.record(), err)
+	}
+
+	return nil
+}
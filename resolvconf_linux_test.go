@@ -0,0 +1,170 @@
+//go:build linux
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	debianVersion = "$ resolvconf --version\n! exit status 99\n"
+	openresolv    = "$ resolvconf --version\nopenresolv 3.12.0\n"
+)
+
+func TestResolvconfApply(t *testing.T) {
+	provider := DNSProvider{Name: "Cloudflare", Servers: []string{"1.1.1.1", "2606:4700:4700::1111"}}
+
+	tests := []struct {
+		name       string
+		settings   ResolvconfConfig
+		transcript string
+	}{
+		{
+			name:     "debian",
+			settings: ResolvconfConfig{Metric: 5, Exclusive: true},
+			transcript: debianVersion + `
+$ resolvconf -a lo.dns-switcher
+< nameserver 1.1.1.1
+< nameserver 2606:4700:4700::1111
+`,
+		},
+		{
+			name:     "openresolv",
+			settings: ResolvconfConfig{Record: "tun0.vpn", Metric: 5},
+			transcript: openresolv + `
+$ resolvconf -m 5 -a tun0.vpn
+< nameserver 1.1.1.1
+< nameserver 2606:4700:4700::1111
+`,
+		},
+		{
+			name:     "openresolv exclusive",
+			settings: ResolvconfConfig{Exclusive: true},
+			transcript: openresolv + `
+$ resolvconf -m 0 -x -a lo.dns-switcher
+< nameserver 1.1.1.1
+< nameserver 2606:4700:4700::1111
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newResolvconf(newTranscriptRunner(t, tt.transcript), tt.settings)
+			if err := r.Apply(provider); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestResolvconfRestore(t *testing.T) {
+	tests := []struct {
+		name       string
+		snap       Snapshot
+		transcript string
+	}{
+		{
+			name: "debian servers",
+			snap: Snapshot{Backend: "resolvconf", Target: "lo.other", Servers: []string{"9.9.9.9"}},
+			transcript: debianVersion + `
+$ resolvconf -a lo.other
+< nameserver 9.9.9.9
+`,
+		},
+		{
+			name: "debian dhcp",
+			snap: Snapshot{Backend: "resolvconf", DHCP: true},
+			transcript: debianVersion + `
+$ resolvconf -d lo.dns-switcher
+`,
+		},
+		{
+			name: "openresolv servers",
+			snap: Snapshot{Backend: "resolvconf", Servers: []string{"9.9.9.9"}},
+			transcript: openresolv + `
+$ resolvconf -m 0 -a lo.dns-switcher
+< nameserver 9.9.9.9
+`,
+		},
+		{
+			name: "openresolv no record",
+			snap: Snapshot{Backend: "resolvconf", Target: "lo.dns-switcher", Servers: []string{}},
+			transcript: openresolv + `
+$ resolvconf -f -d lo.dns-switcher
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newResolvconf(newTranscriptRunner(t, tt.transcript), ResolvconfConfig{})
+			if err := r.Restore(tt.snap); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestResolvconfRestoreOtherBackend(t *testing.T) {
+	r := newResolvconf(newTranscriptRunner(t, debianVersion), ResolvconfConfig{})
+	err := r.Restore(Snapshot{Backend: "resolv.conf", Servers: []string{"1.1.1.1"}})
+	if err == nil || !strings.Contains(err.Error(), "cannot restore a resolv.conf snapshot") {
+		t.Fatalf("got %v, want a backend mismatch error", err)
+	}
+}
+
+func TestResolvconfAddFailure(t *testing.T) {
+	r := newResolvconf(newTranscriptRunner(t, openresolv+`
+$ resolvconf -m 0 -a lo.dns-switcher
+< nameserver 1.1.1.1
+! exit status 1
+`), ResolvconfConfig{})
+
+	err := r.Apply(DNSProvider{Servers: []string{"1.1.1.1"}})
+	want := "failed to register lo.dns-switcher with resolvconf: resolvconf: exit status 1"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got %v, want %q", err, want)
+	}
+}
+
+func TestResolvconfSnapshotOpenresolv(t *testing.T) {
+	tests := []struct {
+		name       string
+		transcript string
+		want       []string
+	}{
+		{
+			name: "registered",
+			transcript: openresolv + `
+$ resolvconf -l lo.dns-switcher
+# resolv.conf from lo.dns-switcher
+nameserver 1.1.1.1
+nameserver 1.0.0.1
+`,
+			want: []string{"1.1.1.1", "1.0.0.1"},
+		},
+		{
+			name: "not registered",
+			transcript: openresolv + `
+$ resolvconf -l lo.dns-switcher
+! exit status 1
+`,
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newResolvconf(newTranscriptRunner(t, tt.transcript), ResolvconfConfig{})
+			snap, err := r.Snapshot()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snap.Target != "lo.dns-switcher" || strings.Join(snap.Servers, " ") != strings.Join(tt.want, " ") || snap.Servers == nil {
+				t.Fatalf("got %+v, want servers %v of lo.dns-switcher", snap, tt.want)
+			}
+		})
+	}
+}
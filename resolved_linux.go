@@ -0,0 +1,541 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	resolvedDropInPath   = "/etc/systemd/resolved.conf.d/dns-switcher.conf"
+	resolvedUpstreamPath = "/run/systemd/resolve/resolv.conf"
+)
+
+func init() {
+	registerBackend("resolved", 20, nil, func() (DNSBackend, error) {
+		if activeTarget.netns != "" {
+			return nil, fmt.Errorf("systemd-resolved cannot be configured per network namespace")
+		}
+		return resolvedBackend{
+			run:       execRunner{},
+			linksPath: filepath.Join(systemStateDir(), "resolved-links.json"),
+			splitPath: filepath.Join(systemStateDir(), "resolved-split.json"),
+		}, nil
+	})
+}
+
+// resolvedBackend configures systemd-resolved through a drop-in instead of
+// resolv.conf, which keeps the stub resolver and adds DNS over TLS and
+// DNSSEC. It is only used when selected, since it changes how every lookup
+// on the machine is resolved.
+//
+// When interfaces are selected, their servers are set at runtime with
+// resolvectl instead, together with the ~. routing domain so lookups prefer
+// them. Snapshots record the servers and domains of every link so those
+// changes can be undone exactly.
+//
+// The links changed this way are recorded in linksPath. Every other link is
+// configured by its network manager, usually from DHCP; snapshots mark it
+// as such and restores leave it alone, so learned servers are never pinned.
+// Links given routing domains for split DNS also keep the settings they had
+// before in splitPath, so removing the rules puts those back.
+type resolvedBackend struct {
+	run       commandRunner
+	linksPath string
+	splitPath string
+}
+
+func (resolvedBackend) Name() string {
+	return "resolved"
+}
+
+// Current returns the upstream servers resolved uses. Inside a root
+// directory nothing is running, so it reports the drop-in instead.
+func (resolvedBackend) Current() ([]string, error) {
+	if !activeTarget.isSystem() {
+		content, err := readResolvedDropIn()
+		if err != nil {
+			return nil, err
+		}
+		return parseResolvedDNS(content), nil
+	}
+
+	if content, err := os.ReadFile(resolvedUpstreamPath); err == nil {
+		return parseNameservers(string(content)), nil
+	}
+	return resolvConfBackend{}.Current()
+}
+
+func (resolvedBackend) Apply(provider DNSProvider) error {
+	return writeResolvedDropIn(resolvedDropIn(provider))
+}
+
+// PreviewFile returns the drop-in as it is and as Apply would write it.
+func (resolvedBackend) PreviewFile(provider DNSProvider) (string, string, string, error) {
+	before, err := readResolvedDropIn()
+	if err != nil {
+		return "", "", "", err
+	}
+	return activeTarget.path(resolvedDropInPath), before, resolvedDropIn(provider), nil
+}
+
+func (b resolvedBackend) Snapshot() (Snapshot, error) {
+	content, err := readResolvedDropIn()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snap := Snapshot{
+		Backend: "resolved",
+		Target:  activeTarget.path(resolvedDropInPath),
+		Servers: parseResolvedDNS(content),
+		Content: content,
+		Missing: content == "",
+	}
+
+	if activeTarget.isSystem() {
+		if links, err := b.links(); err == nil {
+			snap.Interfaces = links
+		}
+	}
+
+	return snap, nil
+}
+
+// Restore puts the drop-in back, or removes it when there was none, which
+// also returns resolved to the servers learned from DHCP, and then the
+// links changed since the snapshot.
+func (b resolvedBackend) Restore(snap Snapshot) error {
+	if snap.Backend != "resolved" {
+		return fmt.Errorf("cannot restore a %s snapshot with the resolved backend", snap.Backend)
+	}
+	if err := writeResolvedDropIn(snap.Content); err != nil {
+		return err
+	}
+	return b.restoreLinks(snap.Interfaces)
+}
+
+// restoreLinks puts back the links of a snapshot that were changed since.
+// Links that were configured by their network manager are reverted to it;
+// links never changed by us are left alone.
+func (b resolvedBackend) restoreLinks(links []InterfaceSnapshot) error {
+	changed, err := b.changedLinks()
+	if err != nil {
+		return err
+	}
+	split, err := b.splitLinks()
+	if err != nil {
+		return err
+	}
+
+	var results []InterfaceResult
+	forgotten := false
+	for _, link := range links {
+		if !changed[link.Name] {
+			continue
+		}
+		if link.DHCP {
+			err = b.revertLink(link.Name)
+		} else {
+			err = b.setLink(link.Name, link.Servers, link.Domains)
+		}
+		if _, ok := split[link.Name]; ok && err == nil {
+			// Whatever routing domains the link had are gone, so there
+			// is nothing left for ClearRoutingDomains to undo.
+			delete(split, link.Name)
+			forgotten = true
+		}
+		results = append(results, InterfaceResult{Interface: link.Name, Err: err})
+	}
+	if forgotten {
+		if err := writeLinkState(b.splitPath, split); err != nil {
+			return err
+		}
+	}
+	return resultsError(results)
+}
+
+// Interfaces lists the links resolved knows about, except loopback, with
+// their current servers.
+func (b resolvedBackend) Interfaces() ([]Interface, error) {
+	links, err := b.links()
+	if err != nil {
+		return nil, err
+	}
+
+	var interfaces []Interface
+	for _, link := range links {
+		interfaces = append(interfaces, Interface{Name: link.Name, Servers: link.Servers})
+	}
+	return interfaces, nil
+}
+
+func (b resolvedBackend) links() ([]InterfaceSnapshot, error) {
+	if !activeTarget.isSystem() {
+		return nil, fmt.Errorf("per-interface DNS can only be set on the running system")
+	}
+
+	output, err := b.run.Run(nil, "resolvectl", "dns")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	servers := parseResolvectlLinks(string(output))
+
+	output, err = b.run.Run(nil, "resolvectl", "domain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list links: %w", err)
+	}
+	domains := parseResolvectlLinks(string(output))
+
+	changed, err := b.changedLinks()
+	if err != nil {
+		return nil, err
+	}
+
+	var links []InterfaceSnapshot
+	for _, link := range servers {
+		if link.name == "lo" {
+			continue
+		}
+		snap := InterfaceSnapshot{Name: link.name, Servers: []string{}, DHCP: !changed[link.name]}
+		for _, server := range link.values {
+			server, _, _ = strings.Cut(server, "#")
+			snap.Servers = append(snap.Servers, server)
+		}
+		for _, domain := range domains {
+			if domain.name == link.name {
+				snap.Domains = domain.values
+			}
+		}
+		links = append(links, snap)
+	}
+	return links, nil
+}
+
+type resolvectlLink struct {
+	name   string
+	values []string
+}
+
+// parseResolvectlLinks parses the per-link lines of "resolvectl dns" and
+// "resolvectl domain", such as "Link 2 (eth0): 192.168.1.1".
+func parseResolvectlLinks(output string) []resolvectlLink {
+	var links []resolvectlLink
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "Link ") {
+			continue
+		}
+		head, values, ok := strings.Cut(line, ":")
+		start, end := strings.Index(head, "("), strings.LastIndex(head, ")")
+		if !ok || start < 0 || end < start {
+			continue
+		}
+		links = append(links, resolvectlLink{name: head[start+1 : end], values: strings.Fields(values)})
+	}
+	return links
+}
+
+// SetInterfaceServers sets the servers of a link and routes all lookups to
+// it. No servers reverts the link to what its network manager configured.
+func (b resolvedBackend) SetInterfaceServers(name string, servers []string) error {
+	if len(servers) == 0 {
+		return b.revertLink(name)
+	}
+	return b.setLink(name, servers, []string{"~."})
+}
+
+// SetRoutingDomains sends lookups for domains and their subdomains to
+// servers, through routing domains on the link, the way VPN clients
+// configure their zones. The settings the link had before its first
+// routing domains are kept for ClearRoutingDomains.
+func (b resolvedBackend) SetRoutingDomains(name string, servers, domains []string) error {
+	if !activeTarget.isSystem() {
+		return fmt.Errorf("routing domains can only be set on the running system")
+	}
+
+	split, err := b.splitLinks()
+	if err != nil {
+		return err
+	}
+	if _, ok := split[name]; !ok {
+		link, err := b.link(name)
+		if err != nil {
+			return err
+		}
+		split[name] = link
+		if err := writeLinkState(b.splitPath, split); err != nil {
+			return err
+		}
+	}
+
+	routing := make([]string, len(domains))
+	for i, domain := range domains {
+		routing[i] = "~" + domain
+	}
+	return b.setLink(name, servers, routing)
+}
+
+// ClearRoutingDomains puts back the settings a link had before
+// SetRoutingDomains first changed it. A link without routing domains is
+// left alone.
+func (b resolvedBackend) ClearRoutingDomains(name string) error {
+	split, err := b.splitLinks()
+	if err != nil {
+		return err
+	}
+	link, ok := split[name]
+	if !ok {
+		return nil
+	}
+
+	if link.DHCP {
+		err = b.revertLink(name)
+	} else {
+		err = b.setLink(name, link.Servers, link.Domains)
+	}
+	if err != nil {
+		return err
+	}
+	delete(split, name)
+	return writeLinkState(b.splitPath, split)
+}
+
+// RoutingInterfaces returns the links given routing domains, by name.
+func (b resolvedBackend) RoutingInterfaces() ([]string, error) {
+	split, err := b.splitLinks()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(split))
+	for name := range split {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// link returns the current settings of one link.
+func (b resolvedBackend) link(name string) (InterfaceSnapshot, error) {
+	links, err := b.links()
+	if err != nil {
+		return InterfaceSnapshot{}, err
+	}
+	for _, link := range links {
+		if link.Name == name {
+			return link, nil
+		}
+	}
+	return InterfaceSnapshot{}, fmt.Errorf("unknown link %q", name)
+}
+
+// revertLink hands a link back to its network manager.
+func (b resolvedBackend) revertLink(name string) error {
+	if _, err := b.run.Run(nil, "resolvectl", "revert", name); err != nil {
+		return fmt.Errorf("failed to revert %s: %w", name, err)
+	}
+	return b.markLink(name, false)
+}
+
+// setLink sets the servers and domains of a link; an empty list clears
+// them.
+func (b resolvedBackend) setLink(name string, servers, domains []string) error {
+	if len(servers) == 0 {
+		servers = []string{""}
+	}
+	if len(domains) == 0 {
+		domains = []string{""}
+	}
+
+	if _, err := b.run.Run(nil, "resolvectl", append([]string{"dns", name}, servers...)...); err != nil {
+		return fmt.Errorf("failed to set servers of %s: %w", name, err)
+	}
+	if _, err := b.run.Run(nil, "resolvectl", append([]string{"domain", name}, domains...)...); err != nil {
+		return fmt.Errorf("failed to set domains of %s: %w", name, err)
+	}
+	return b.markLink(name, true)
+}
+
+// changedLinks returns the links whose settings we set at runtime.
+func (b resolvedBackend) changedLinks() (map[string]bool, error) {
+	var names []string
+	if err := readLinkState(b.linksPath, &names); err != nil {
+		return nil, err
+	}
+	changed := map[string]bool{}
+	for _, name := range names {
+		changed[name] = true
+	}
+	return changed, nil
+}
+
+// markLink records whether the settings of a link are ours.
+func (b resolvedBackend) markLink(name string, ours bool) error {
+	changed, err := b.changedLinks()
+	if err != nil {
+		return err
+	}
+	if changed[name] == ours {
+		return nil
+	}
+	changed[name] = ours
+
+	names := []string{}
+	for link, ours := range changed {
+		if ours {
+			names = append(names, link)
+		}
+	}
+	sort.Strings(names)
+	return writeLinkState(b.linksPath, names)
+}
+
+// splitLinks returns the links given routing domains, with the settings
+// they had before.
+func (b resolvedBackend) splitLinks() (map[string]InterfaceSnapshot, error) {
+	split := map[string]InterfaceSnapshot{}
+	if err := readLinkState(b.splitPath, &split); err != nil {
+		return nil, err
+	}
+	return split, nil
+}
+
+// readLinkState decodes the state file at path into v, which is left as it
+// is when there is no file.
+func readLinkState(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeLinkState(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func (resolvedBackend) Flush() error {
+	return reloadSystemdResolved()
+}
+
+func (resolvedBackend) Restarts() []string {
+	return resolvedRestarts()
+}
+
+func (resolvedBackend) Capabilities() Capabilities {
+	return Capabilities{NeedsAdmin: true, DoT: true, DNSSEC: true, Flush: activeTarget.isSystem()}
+}
+
+// resolvedDropIn renders the resolved.conf drop-in for a provider.
+// Domains=~. routes every lookup to its servers instead of to per-link
+// servers learned from DHCP. FallbackDNS is left alone: resolved only uses
+// it when no server is configured at all, which DNS= rules out.
+func resolvedDropIn(provider DNSProvider) string {
+	var servers []string
+	for _, dns := range provider.Servers {
+		if provider.TLSName != "" {
+			dns += "#" + provider.TLSName
+		}
+		servers = append(servers, dns)
+	}
+
+	dot := config.Resolved.DNSOverTLS
+	if dot == "" {
+		dot = "no"
+		if provider.TLSName != "" {
+			dot = "yes"
+		}
+	}
+
+	dnssec := config.Resolved.DNSSEC
+	if dnssec == "" {
+		dnssec = "no"
+		if provider.DNSSEC {
+			dnssec = "allow-downgrade"
+		}
+	}
+
+	var content strings.Builder
+	content.WriteString(fmt.Sprintf("# Generated by dns-switcher on %s\n", time.Now().Format("2006-01-02 15:04:05")))
+	content.WriteString(fmt.Sprintf("# Provider: %s\n", provider.Name))
+	content.WriteString("[Resolve]\n")
+	content.WriteString(fmt.Sprintf("DNS=%s\n", strings.Join(servers, " ")))
+	content.WriteString("Domains=~.\n")
+	content.WriteString(fmt.Sprintf("DNSOverTLS=%s\n", dot))
+	content.WriteString(fmt.Sprintf("DNSSEC=%s\n", dnssec))
+
+	return content.String()
+}
+
+// parseResolvedDNS returns the servers of the DNS= line of a resolved.conf
+// file, without the "#server-name" suffixes.
+func parseResolvedDNS(content string) []string {
+	var servers []string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "DNS=") {
+			continue
+		}
+		for _, field := range strings.Fields(strings.TrimPrefix(line, "DNS=")) {
+			server, _, _ := strings.Cut(field, "#")
+			servers = append(servers, server)
+		}
+	}
+
+	return servers
+}
+
+func readResolvedDropIn() (string, error) {
+	path := activeTarget.path(resolvedDropInPath)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(content), nil
+}
+
+func writeResolvedDropIn(content string) error {
+	path := activeTarget.path(resolvedDropInPath)
+	if content == "" {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	if err := writeFileAtomic(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+//go:build linux
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestResolved returns a resolved backend replaying transcript whose
+// runtime changes are recorded to have been made to the changed links.
+func newTestResolved(t *testing.T, transcript string, changed ...string) resolvedBackend {
+	t.Helper()
+	dir := t.TempDir()
+	b := resolvedBackend{
+		run:       newTranscriptRunner(t, transcript),
+		linksPath: filepath.Join(dir, "resolved-links.json"),
+		splitPath: filepath.Join(dir, "resolved-split.json"),
+	}
+	for _, name := range changed {
+		if err := b.markLink(name, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b
+}
+
+func checkChangedLinks(t *testing.T, b resolvedBackend, want map[string]bool) {
+	t.Helper()
+	changed, err := b.changedLinks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, want) {
+		t.Fatalf("changed links are %v, want %v", changed, want)
+	}
+}
+
+func TestResolvedLinks(t *testing.T) {
+	b := newTestResolved(t, `
+$ resolvectl dns
+Global:
+Link 1 (lo):
+Link 2 (eth0): 192.168.1.1
+Link 3 (wlan0): 1.1.1.1#cloudflare-dns.com 1.0.0.1
+$ resolvectl domain
+Global:
+Link 1 (lo):
+Link 2 (eth0): lan
+Link 3 (wlan0): ~.
+`, "wlan0")
+
+	links, err := b.links()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InterfaceSnapshot{
+		{Name: "eth0", Servers: []string{"192.168.1.1"}, DHCP: true, Domains: []string{"lan"}},
+		{Name: "wlan0", Servers: []string{"1.1.1.1", "1.0.0.1"}, Domains: []string{"~."}},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Fatalf("got %+v, want %+v", links, want)
+	}
+}
+
+func TestResolvedRestoreLinks(t *testing.T) {
+	// eth0 was never changed, wlan0 was changed after the snapshot and eth1
+	// was already ours when it was taken.
+	b := newTestResolved(t, `
+$ resolvectl revert wlan0
+$ resolvectl dns eth1 9.9.9.9
+$ resolvectl domain eth1 ~corp.example
+`, "wlan0", "eth1")
+
+	err := b.restoreLinks([]InterfaceSnapshot{
+		{Name: "eth0", Servers: []string{"192.168.1.1"}, DHCP: true, Domains: []string{"lan"}},
+		{Name: "wlan0", Servers: []string{"192.168.0.1"}, DHCP: true},
+		{Name: "eth1", Servers: []string{"9.9.9.9"}, Domains: []string{"~corp.example"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkChangedLinks(t, b, map[string]bool{"eth1": true})
+}
+
+func TestResolvedSetInterfaceServers(t *testing.T) {
+	b := newTestResolved(t, `
+$ resolvectl dns eth0 1.1.1.1 1.0.0.1
+$ resolvectl domain eth0 ~.
+$ resolvectl revert eth0
+`)
+
+	if err := b.SetInterfaceServers("eth0", []string{"1.1.1.1", "1.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+	checkChangedLinks(t, b, map[string]bool{"eth0": true})
+
+	if err := b.SetInterfaceServers("eth0", nil); err != nil {
+		t.Fatal(err)
+	}
+	checkChangedLinks(t, b, map[string]bool{})
+}
+
+func TestResolvedSetLinkFailure(t *testing.T) {
+	b := newTestResolved(t, `
+$ resolvectl dns eth0 1.1.1.1
+! exit status 1
+`)
+
+	if err := b.SetInterfaceServers("eth0", []string{"1.1.1.1"}); err == nil {
+		t.Fatal("a failed resolvectl call was not reported")
+	}
+	checkChangedLinks(t, b, map[string]bool{})
+}
+
+func checkRoutingInterfaces(t *testing.T, b resolvedBackend, want ...string) {
+	t.Helper()
+	got, err := b.RoutingInterfaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got)+len(want) > 0 && !reflect.DeepEqual(got, want) {
+		t.Fatalf("links with routing domains are %v, want %v", got, want)
+	}
+}
+
+func TestResolvedRoutingDomains(t *testing.T) {
+	// eth0 comes from DHCP and tun0 was set by us; both are put back as
+	// they were before the first routing domains, however often those
+	// change.
+	b := newTestResolved(t, `
+$ resolvectl dns
+Link 2 (eth0): 192.168.1.1
+Link 5 (tun0): 10.8.0.1
+$ resolvectl domain
+Link 2 (eth0): lan
+Link 5 (tun0): vpn.example
+$ resolvectl dns eth0 10.0.0.53
+$ resolvectl domain eth0 ~corp.example
+$ resolvectl dns eth0 10.0.0.53
+$ resolvectl domain eth0 ~corp.example ~intra.example
+$ resolvectl dns
+Link 2 (eth0): 10.0.0.53
+Link 5 (tun0): 10.8.0.1
+$ resolvectl domain
+Link 2 (eth0): ~corp.example ~intra.example
+Link 5 (tun0): vpn.example
+$ resolvectl dns tun0 10.8.0.1
+$ resolvectl domain tun0 ~ir
+$ resolvectl revert eth0
+$ resolvectl dns tun0 10.8.0.1
+$ resolvectl domain tun0 vpn.example
+`, "tun0")
+
+	if err := b.SetRoutingDomains("eth0", []string{"10.0.0.53"}, []string{"corp.example"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetRoutingDomains("eth0", []string{"10.0.0.53"}, []string{"corp.example", "intra.example"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.SetRoutingDomains("tun0", []string{"10.8.0.1"}, []string{"ir"}); err != nil {
+		t.Fatal(err)
+	}
+	checkRoutingInterfaces(t, b, "eth0", "tun0")
+	checkChangedLinks(t, b, map[string]bool{"eth0": true, "tun0": true})
+
+	for _, name := range []string{"eth0", "tun0", "wlan0"} {
+		if err := b.ClearRoutingDomains(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkRoutingInterfaces(t, b)
+	checkChangedLinks(t, b, map[string]bool{"tun0": true})
+}
+
+func TestResolvedRestoreForgetsRoutingDomains(t *testing.T) {
+	b := newTestResolved(t, `
+$ resolvectl dns
+Link 2 (eth0): 192.168.1.1
+$ resolvectl domain
+Link 2 (eth0): lan
+$ resolvectl dns eth0 10.0.0.53
+$ resolvectl domain eth0 ~corp.example
+$ resolvectl revert eth0
+`)
+
+	if err := b.SetRoutingDomains("eth0", []string{"10.0.0.53"}, []string{"corp.example"}); err != nil {
+		t.Fatal(err)
+	}
+	err := b.restoreLinks([]InterfaceSnapshot{
+		{Name: "eth0", Servers: []string{"192.168.1.1"}, DHCP: true, Domains: []string{"lan"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRoutingInterfaces(t, b)
+
+	// Nothing is left to undo.
+	if err := b.ClearRoutingDomains("eth0"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolvedDropIn(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider DNSProvider
+		settings ResolvedConfig
+		want     string
+	}{
+		{
+			name:     "plain",
+			provider: DNSProvider{Name: "Cloudflare", Servers: []string{"1.1.1.1", "2606:4700:4700::1111"}},
+			want:     "DNS=1.1.1.1 2606:4700:4700::1111\nDomains=~.\nDNSOverTLS=no\nDNSSEC=no\n",
+		},
+		{
+			name:     "tls and dnssec",
+			provider: DNSProvider{Name: "Quad9", Servers: []string{"9.9.9.9", "149.112.112.112"}, TLSName: "dns.quad9.net", DNSSEC: true},
+			want:     "DNS=9.9.9.9#dns.quad9.net 149.112.112.112#dns.quad9.net\nDomains=~.\nDNSOverTLS=yes\nDNSSEC=allow-downgrade\n",
+		},
+		{
+			name:     "configured",
+			provider: DNSProvider{Name: "Quad9", Servers: []string{"9.9.9.9"}, TLSName: "dns.quad9.net", DNSSEC: true},
+			settings: ResolvedConfig{DNSOverTLS: "opportunistic", DNSSEC: "yes"},
+			want:     "DNS=9.9.9.9#dns.quad9.net\nDomains=~.\nDNSOverTLS=opportunistic\nDNSSEC=yes\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := config
+			t.Cleanup(func() { config = saved })
+			config.Resolved = tt.settings
+
+			content := resolvedDropIn(tt.provider)
+			header := "# Provider: " + tt.provider.Name + "\n[Resolve]\n"
+			_, body, ok := strings.Cut(content, header)
+			if !ok || !strings.HasPrefix(content, "# Generated by dns-switcher") {
+				t.Fatalf("drop-in has no header:\n%s", content)
+			}
+			if body != tt.want {
+				t.Errorf("drop-in is\n%s\nwant\n%s", body, tt.want)
+			}
+
+			// The servers read back are the ones written, without the
+			// TLS names.
+			if got := parseResolvedDNS(content); !reflect.DeepEqual(got, tt.provider.Servers) {
+				t.Errorf("parsed %v, want %v", got, tt.provider.Servers)
+			}
+		})
+	}
+}
+
+func TestParseResolvedDNS(t *testing.T) {
+	content := `[Resolve]
+# DNS=192.0.2.1
+DNS=1.1.1.1#cloudflare-dns.com
+  DNS=9.9.9.9 149.112.112.112
+FallbackDNS=8.8.8.8
+`
+	want := []string{"1.1.1.1", "9.9.9.9", "149.112.112.112"}
+	if got := parseResolvedDNS(content); !reflect.DeepEqual(got, want) {
+		t.Fatalf("parsed %v, want %v", got, want)
+	}
+	if got := parseResolvedDNS(""); got != nil {
+		t.Fatalf("parsed %v from nothing, want no servers", got)
+	}
+}
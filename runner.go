@@ -0,0 +1,38 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// commandRunner runs an external program and returns its standard output.
+// Backends take one instead of calling os/exec directly so the commands they
+// issue can be checked without the real binaries installed.
+type commandRunner interface {
+	Run(stdin []byte, name string, args ...string) ([]byte, error)
+}
+
+type execRunner struct{}
+
+func (execRunner) Run(stdin []byte, name string, args ...string) ([]byte, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if msg := strings.TrimSpace(string(exitErr.Stderr)); msg != "" {
+				return output, fmt.Errorf("%s: %w: %s", name, err, msg)
+			}
+		}
+		return output, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return output, nil
+}
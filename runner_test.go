@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// transcriptStep is one recorded command with what it printed.
+type transcriptStep struct {
+	command string
+	stdin   string
+	output  string
+	err     string
+}
+
+// parseTranscript reads a recorded session. A command starts with "$ ",
+// "> " lines continue it over several lines, "< " lines are its standard
+// input and a "! " line makes it fail with that message. Every other line
+// up to the next command is its output. Lines before the first command are
+// comments. "! executable file not found in $PATH" fails the way a missing
+// binary does.
+func parseTranscript(text string) []transcriptStep {
+	var steps []transcriptStep
+	var stdin, output []string
+
+	finish := func() {
+		if len(steps) == 0 {
+			return
+		}
+		step := &steps[len(steps)-1]
+		for len(output) > 0 && output[len(output)-1] == "" {
+			output = output[:len(output)-1]
+		}
+		if len(stdin) > 0 {
+			step.stdin = strings.Join(stdin, "\n") + "\n"
+		}
+		if len(output) > 0 {
+			step.output = strings.Join(output, "\n") + "\n"
+		}
+		stdin, output = nil, nil
+	}
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "$ "):
+			finish()
+			steps = append(steps, transcriptStep{command: line[2:]})
+		case len(steps) == 0:
+		case strings.HasPrefix(line, "> ") && len(stdin)+len(output) == 0:
+			steps[len(steps)-1].command += "\n" + line[2:]
+		case strings.HasPrefix(line, "< ") && len(output) == 0:
+			stdin = append(stdin, line[2:])
+		case strings.HasPrefix(line, "! ") && len(output) == 0:
+			steps[len(steps)-1].err = line[2:]
+		default:
+			output = append(output, line)
+		}
+	}
+	finish()
+	return steps
+}
+
+// transcriptRunner replays a transcript: every command run must be the next
+// one recorded, and gets the recorded output back.
+type transcriptRunner struct {
+	t     *testing.T
+	steps []transcriptStep
+	next  int
+}
+
+// newTranscriptRunner replays text and fails t if commands recorded in it
+// are left over at the end of the test.
+func newTranscriptRunner(t *testing.T, text string) *transcriptRunner {
+	t.Helper()
+	r := &transcriptRunner{t: t, steps: parseTranscript(text)}
+	t.Cleanup(func() {
+		if r.next < len(r.steps) {
+			t.Errorf("%d recorded commands were not run, the first being:\n%s", len(r.steps)-r.next, r.steps[r.next].command)
+		}
+	})
+	return r
+}
+
+// loadTranscript replays the transcript testdata/name.
+func loadTranscript(t *testing.T, name string) *transcriptRunner {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTranscriptRunner(t, strings.ReplaceAll(string(data), "\r\n", "\n"))
+}
+
+func (r *transcriptRunner) Run(stdin []byte, name string, args ...string) ([]byte, error) {
+	command := renderCommand(name, args)
+	if r.next >= len(r.steps) {
+		r.t.Errorf("unexpected command:\n%s", command)
+		return nil, errors.New("unexpected command")
+	}
+	step := r.steps[r.next]
+	r.next++
+
+	if command != step.command {
+		r.t.Errorf("command %d:\ngot:\n%s\nwant:\n%s", r.next, command, step.command)
+	}
+	if string(stdin) != step.stdin {
+		r.t.Errorf("stdin of command %d:\ngot:\n%s\nwant:\n%s", r.next, stdin, step.stdin)
+	}
+	if step.err == exec.ErrNotFound.Error() {
+		return nil, fmt.Errorf("%s: %w", name, &exec.Error{Name: name, Err: exec.ErrNotFound})
+	}
+	if step.err != "" {
+		return []byte(step.output), fmt.Errorf("%s: %s", name, step.err)
+	}
+	return []byte(step.output), nil
+}
+
+// renderCommand writes a command line the way transcripts record it.
+// Arguments with spaces or quotes are single-quoted like in a shell, and
+// the argument of -EncodedCommand is decoded so scripts can be read.
+func renderCommand(name string, args []string) string {
+	words := []string{name}
+	for i, arg := range args {
+		if i > 0 && args[i-1] == "-EncodedCommand" {
+			words = append(words, decodePowershell(arg))
+			continue
+		}
+		if arg == "" || strings.ContainsAny(arg, " \t'\"") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		words = append(words, arg)
+	}
+	return strings.Join(words, " ")
+}
+
+// decodePowershell undoes encodePowershell.
+func decodePowershell(encoded string) string {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(data)%2 != 0 {
+		return "<invalid encoded command " + encoded + ">"
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
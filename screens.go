@@ -0,0 +1,293 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// screen is the step of the switch the TUI shows. A switch goes from
+// select to confirm, applying, validation and monitor; an error on the way
+// returns to select. Detail shows one provider of the selection and lookup
+// compares the answers of every provider for a domain.
+type screen int
+
+const (
+	screenSelect screen = iota
+	screenConfirm
+	screenApplying
+	screenValidating
+	screenMonitor
+	screenDetail
+	screenLookup
+)
+
+var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
+
+type spinnerMsg struct{}
+
+func spinnerTick() tea.Cmd {
+	return tea.Tick(100*time.Millisecond, func(time.Time) tea.Msg {
+		return spinnerMsg{}
+	})
+}
+
+// spin starts the spinner unless it is already turning.
+func (m model) spin() (model, tea.Cmd) {
+	if m.spinning {
+		return m, nil
+	}
+	m.spinning = true
+	return m, spinnerTick()
+}
+
+// spinnerFrame is the current frame of the spinner. Plain output shows
+// a fixed marker instead of animating.
+func (m model) spinnerFrame() string {
+	if plainOutput {
+		return "..."
+	}
+	return spinnerFrames[m.spinner%len(spinnerFrames)]
+}
+
+// spinnerNeeded reports whether anything on screen is still in progress.
+func (m model) spinnerNeeded() bool {
+	return m.screen == screenApplying || m.screen == screenValidating || (m.screen == screenConfirm && !m.planned) ||
+		len(m.testing) > 0 || len(m.serverTesting) > 0 || m.lookupPending > 0 || m.backupBusy
+}
+
+// planMsg carries what switching to provider would change.
+type planMsg struct {
+	provider string
+	plan     ApplyPlan
+	err      error
+}
+
+func planCmd(provider DNSProvider) tea.Cmd {
+	return func() tea.Msg {
+		plan, err := PlanApply(provider)
+		return planMsg{provider: provider.Name, plan: plan, err: err}
+	}
+}
+
+// applyMsg reports the outcome of applyCmd. Lines describe what was done,
+// ready to be shown.
+type applyMsg struct {
+	lines    []string
+	newDNS   []string
+	splitErr error
+	err      error
+}
+
+// validateMsg reports the outcome of validateCmd.
+type validateMsg struct {
+	err error
+}
+
+// applyCmd switches to provider and makes the system pick it up, off the
+// event loop.
+func applyCmd(provider DNSProvider) tea.Cmd {
+	return func() tea.Msg {
+		results, err := ApplyProvider(provider)
+		lines := interfaceResultLines(results)
+		if err != nil {
+			return applyMsg{lines: lines, err: err}
+		}
+
+		lines = append([]string{successStyle.Render("Configuration updated")}, lines...)
+		backend := currentBackend()
+		if err := backend.Flush(); err != nil {
+			lines = append(lines, errorStyle.Render("Warning: "+err.Error()))
+		} else if backend.Capabilities().Flush {
+			lines = append(lines, successStyle.Render("Service reloaded"))
+		}
+		lines = append(lines, flushResultLines(FlushCaches())...)
+		splitLines, splitErr := splitResultLines()
+		lines = append(lines, splitLines...)
+
+		newDNS, _ := backend.Current()
+		return applyMsg{lines: lines, newDNS: newDNS, splitErr: splitErr}
+	}
+}
+
+func validateCmd(provider DNSProvider) tea.Cmd {
+	return func() tea.Msg {
+		_, err := ValidateDNS(provider.Servers)
+		return validateMsg{err: err}
+	}
+}
+
+// confirm shows what switching to provider changes and asks first, unless
+// --yes was given.
+func (m model) confirm(provider DNSProvider) (model, tea.Cmd) {
+	m.pending = provider
+	m.applyError = ""
+	if assumeYes {
+		return m.startApply()
+	}
+
+	m.screen = screenConfirm
+	m.plan = ApplyPlan{}
+	m.planned = false
+	m.planError = ""
+	var spin tea.Cmd
+	m, spin = m.spin()
+	return m, tea.Batch(planCmd(provider), spin)
+}
+
+// planReady records the plan of the pending switch, unless the user moved on.
+func (m model) planReady(msg planMsg) model {
+	if m.screen != screenConfirm || msg.provider != m.pending.Name {
+		return m
+	}
+	m.plan = msg.plan
+	m.planned = true
+	if msg.err != nil {
+		m.planError = msg.err.Error()
+	}
+	return m
+}
+
+// startApply switches to the pending provider.
+func (m model) startApply() (model, tea.Cmd) {
+	m.screen = screenApplying
+	m.progress = nil
+	var spin tea.Cmd
+	m, spin = m.spin()
+	return m, tea.Batch(applyCmd(m.pending), spin)
+}
+
+func (m model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "n", "backspace":
+		m.screen = screenSelect
+
+	case "enter", "y":
+		return m.startApply()
+	}
+	return m, nil
+}
+
+// applied moves on to validation once the switch is done, or back to the
+// selection with the error.
+func (m model) applied(msg applyMsg) (tea.Model, tea.Cmd) {
+	m.progress = msg.lines
+	if msg.err != nil {
+		m.screen = screenSelect
+		m.applyError = "Switching to " + m.pending.Name + " failed: " + msg.err.Error()
+		return m, nil
+	}
+
+	m.newDNS = msg.newDNS
+	m.splitErr = msg.splitErr
+	if m.pending.Name == "Reset to Default" {
+		return m.enterMonitor()
+	}
+	m.screen = screenValidating
+	return m, validateCmd(m.pending)
+}
+
+func (m model) validated(msg validateMsg) (tea.Model, tea.Cmd) {
+	if msg.err != nil {
+		m.screen = screenSelect
+		m.applyError = fmt.Sprintf("Switched to %s, but validation failed: %v", m.pending.Name, msg.err)
+		return m, nil
+	}
+	return m.enterMonitor()
+}
+
+// enterMonitor starts monitoring the provider just applied.
+func (m model) enterMonitor() (tea.Model, tea.Cmd) {
+	provider := m.pending
+
+	// Reset restores whatever the system had, so monitor that
+	monitoredDNS := provider.Servers
+	if provider.Name == "Reset to Default" {
+		monitoredDNS = m.newDNS
+	}
+	routes, _ := SplitRoutes()
+
+	m.screen = screenMonitor
+	m.monitorStats = MonitorStats{
+		ProviderName: provider.Name,
+		Provider:     provider,
+		AutoReapply:  config.Drift.AutoReapply,
+		Split:        routes,
+		SplitErr:     m.splitErr,
+		CurrentDNS:   monitoredDNS,
+		LastLatency:  provider.Latency,
+	}
+
+	var probe tea.Cmd
+	m, probe = m.probe()
+	if m.ticking {
+		return m, probe
+	}
+	m.ticking = true
+	return m, tea.Batch(probe, doTick())
+}
+
+func (m model) confirmView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("  Switch to "+m.pending.Name+"?") + "\n\n")
+
+	servers := strings.Join(m.pending.Servers, ", ")
+	if m.pending.Name == "Reset to Default" {
+		servers = "original system settings"
+	}
+	b.WriteString(fmt.Sprintf("  %s %s\n", headerStyle.Render("Servers:   "), serverStyle.Render(servers)))
+	b.WriteString(fmt.Sprintf("  %s %s\n", headerStyle.Render("Backend:   "), infoStyle.Render(currentBackend().Name())))
+	if selected := SelectedInterfaces(); len(selected) > 0 {
+		b.WriteString(fmt.Sprintf("  %s %s\n", headerStyle.Render("Interfaces:"), infoStyle.Render(strings.Join(selected, ", "))))
+	}
+	b.WriteString("\n")
+
+	switch {
+	case !m.planned:
+		b.WriteString("  " + serverStyle.Render(m.spinnerFrame()) + " " + infoStyle.Render("Working out the changes...") + "\n")
+	case m.planError != "":
+		b.WriteString(errorStyle.Render("  Cannot show the changes: "+m.planError) + "\n")
+	default:
+		for _, line := range planLines(m.plan) {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	b.WriteString(helpStyle.Render("  enter/y: apply • esc/n: back • q: quit") + "\n")
+
+	return b.String()
+}
+
+// progressView shows the switch and validation while they run.
+func (m model) progressView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("  Switching to "+m.pending.Name) + "\n\n")
+
+	if m.screen == screenApplying {
+		b.WriteString("  " + serverStyle.Render(m.spinnerFrame()) + " " + infoStyle.Render("Updating DNS configuration...") + "\n")
+	} else {
+		for _, line := range m.progress {
+			b.WriteString("  " + line + "\n")
+		}
+		b.WriteString("\n")
+		b.WriteString("  " + serverStyle.Render(m.spinnerFrame()) + " " + infoStyle.Render("Testing DNS resolution...") + "\n")
+	}
+	b.WriteString("\n")
+
+	b.WriteString(helpStyle.Render("  ctrl+c: quit") + "\n")
+
+	return b.String()
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// SplitRoute is a SplitRule with its provider looked up.
+type SplitRoute struct {
+	Domain    string
+	Provider  DNSProvider
+	Interface string
+}
+
+// routingBackend is implemented by backends that can send lookups for some
+// domains to the servers of one interface. ClearRoutingDomains puts back
+// what the interface had before SetRoutingDomains first changed it, and
+// RoutingInterfaces lists the interfaces that still need that.
+type routingBackend interface {
+	SetRoutingDomains(iface string, servers, domains []string) error
+	ClearRoutingDomains(iface string) error
+	RoutingInterfaces() ([]string, error)
+}
+
+// SplitRoutes resolves the split rules of the config, in order.
+func SplitRoutes() ([]SplitRoute, error) {
+	var routes []SplitRoute
+	for _, rule := range config.Split {
+		domain := normalizeDomain(rule.Domain)
+		if domain == "" {
+			return nil, fmt.Errorf("split rule without a domain")
+		}
+		provider, err := resolveProvider(rule.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("split rule for %s: %w", domain, err)
+		}
+		routes = append(routes, SplitRoute{Domain: domain, Provider: provider, Interface: rule.Interface})
+	}
+	return routes, nil
+}
+
+// normalizeDomain lowercases a domain and drops a leading "*." and the dots
+// around it, so "*.ir", "ir." and "IR" are the same rule.
+func normalizeDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	domain = strings.TrimPrefix(domain, "*.")
+	return strings.Trim(domain, ".")
+}
+
+// matchRoute returns the first route whose domain is name or a parent of
+// it.
+func matchRoute(routes []SplitRoute, name string) (SplitRoute, bool) {
+	name = normalizeDomain(name)
+	for _, route := range routes {
+		if name == route.Domain || strings.HasSuffix(name, "."+route.Domain) {
+			return route, true
+		}
+	}
+	return SplitRoute{}, false
+}
+
+// routedByBackend reports whether the backend enforces route itself rather
+// than the local proxy.
+func routedByBackend(route SplitRoute) bool {
+	_, ok := currentBackend().(routingBackend)
+	return ok && route.Interface != ""
+}
+
+// SplitVia describes what enforces route, given the outcome of
+// SplitProxyStatus for the routes it belongs to.
+func SplitVia(route SplitRoute, proxyErr error) string {
+	if routedByBackend(route) {
+		return currentBackend().Name() + " on " + route.Interface
+	}
+	if proxyErr != nil {
+		return "not enforced: " + proxyErr.Error()
+	}
+	return "local proxy"
+}
+
+// SplitProxyStatus checks that the local proxy enforces the routes the
+// backend does not. It is nil when the backend enforces them all.
+func SplitProxyStatus(routes []SplitRoute) error {
+	for _, route := range routes {
+		if !routedByBackend(route) {
+			return checkLocalProxy()
+		}
+	}
+	return nil
+}
+
+// checkLocalProxy checks that the system resolves through the local proxy:
+// its DNS servers must include an address the proxy listens on, and a
+// proxy must answer there. Rules added after the proxy started apply once
+// it is restarted.
+func checkLocalProxy() error {
+	servers, err := currentBackend().Current()
+	if err != nil {
+		return fmt.Errorf("cannot read the system DNS servers: %w", err)
+	}
+	address := proxyAddress(config.Proxy.Listen, servers)
+	if address == "" {
+		return fmt.Errorf("the system DNS servers do not point at the local proxy")
+	}
+
+	query, err := lookupQuery(dnsmessage.MustNewName("."), dnsmessage.TypeNS)
+	if err != nil {
+		return err
+	}
+	if _, err := exchangeUDP(address, query, time.Second); err != nil {
+		return fmt.Errorf("the local proxy is not running on %s", address)
+	}
+	return nil
+}
+
+// ApplySplitRules makes the backend route the domains of the rules that
+// name an interface, one result per interface. The other rules need no
+// setup here: the local proxy applies them to every lookup it forwards,
+// provided the system uses it, which SplitProxyStatus checks.
+func ApplySplitRules() ([]InterfaceResult, error) {
+	routes, err := SplitRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []InterfaceResult
+	err = withLock(func() error {
+		// Reset to default must be able to undo the routing domains even
+		// when no provider was ever applied.
+		if slices.ContainsFunc(routes, routedByBackend) {
+			if err := EnsurePristineSnapshot(); err != nil {
+				return fmt.Errorf("snapshot failed: %w", err)
+			}
+		}
+		results = applySplitRoutes(routes)
+		return resultsError(results)
+	})
+	return results, err
+}
+
+// RemoveSplitRules puts back the interfaces the backend routes split
+// domains on, one result per interface. Rules left to the local proxy go
+// with the proxy.
+func RemoveSplitRules() ([]InterfaceResult, error) {
+	backend, ok := currentBackend().(routingBackend)
+	if !ok {
+		return nil, nil
+	}
+
+	var results []InterfaceResult
+	err := withLock(func() error {
+		ifaces, err := backend.RoutingInterfaces()
+		if err != nil {
+			return err
+		}
+		for _, iface := range ifaces {
+			results = append(results, InterfaceResult{Interface: iface, Err: backend.ClearRoutingDomains(iface)})
+		}
+		return resultsError(results)
+	})
+	return results, err
+}
+
+func applySplitRoutes(routes []SplitRoute) []InterfaceResult {
+	backend, ok := currentBackend().(routingBackend)
+	if !ok {
+		return nil
+	}
+
+	// resolved sends all of an interface's routed domains to all of its
+	// servers, so the rules of one interface must share a provider.
+	var order []string
+	byInterface := map[string][]SplitRoute{}
+	for _, route := range routes {
+		if route.Interface == "" {
+			continue
+		}
+		if _, seen := byInterface[route.Interface]; !seen {
+			order = append(order, route.Interface)
+		}
+		byInterface[route.Interface] = append(byInterface[route.Interface], route)
+	}
+
+	var results []InterfaceResult
+	for _, iface := range order {
+		group := byInterface[iface]
+		provider := group[0].Provider
+
+		var domains []string
+		var err error
+		for _, route := range group {
+			if !sameServers(route.Provider.Servers, provider.Servers) {
+				err = fmt.Errorf("rules for %s and %s use different providers", group[0].Domain, route.Domain)
+				break
+			}
+			domains = append(domains, route.Domain)
+		}
+		if err == nil {
+			err = backend.SetRoutingDomains(iface, provider.Servers, domains)
+		}
+		results = append(results, InterfaceResult{Interface: iface, Err: err})
+	}
+	return results
+}
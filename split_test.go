@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+// useSplitRules configures rules for the local proxy, which listens on
+// 127.0.0.1:53 as answered by proxy.
+func useSplitRules(t *testing.T, proxy *stubServer) []SplitRoute {
+	t.Helper()
+	config.Proxy.Listen = []string{"127.0.0.1:53"}
+	config.Split = []SplitRule{
+		{Domain: "*.ir", Provider: "10.0.0.2"},
+		{Domain: "corp.example", Provider: "10.0.0.3", Interface: "tun0"},
+	}
+	useStubServers(t, map[string]*stubServer{"127.0.0.1": proxy})
+
+	routes, err := SplitRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return routes
+}
+
+func TestSplitProxyStatus(t *testing.T) {
+	answering := func(t *testing.T) *stubServer { return newStubServer(t, answerWith("192.0.2.1")) }
+	silent := func(t *testing.T) *stubServer { return newStubServer(t, func([]byte) []byte { return nil }) }
+
+	tests := []struct {
+		name    string
+		proxy   func(*testing.T) *stubServer
+		servers []string
+		wantErr string
+	}{
+		{"running and in use", answering, []string{"127.0.0.1"}, ""},
+		{"running, not in use", answering, []string{"192.168.1.1"}, "the system DNS servers do not point at the local proxy"},
+		{"in use, not running", silent, []string{"127.0.0.1"}, "the local proxy is not running on 127.0.0.1:53"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := useFakeBackend(t)
+			routes := useSplitRules(t, tt.proxy(t))
+			if err := f.SetInterfaceServers("eth0", tt.servers); err != nil {
+				t.Fatal(err)
+			}
+
+			err := SplitProxyStatus(routes)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("got %v, want %s", err, tt.wantErr)
+			}
+
+			// The fake backend routes nothing itself, so every rule
+			// depends on the proxy.
+			want := "local proxy"
+			if tt.wantErr != "" {
+				want = "not enforced: " + tt.wantErr
+			}
+			for _, route := range routes {
+				if got := SplitVia(route, err); got != want {
+					t.Errorf("%s is enforced by %q, want %q", route.Domain, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyAddress(t *testing.T) {
+	listen := []string{"127.0.0.1:53", "[::1]:53", "127.0.0.2:5353"}
+	tests := []struct {
+		servers []string
+		want    string
+	}{
+		{[]string{"127.0.0.1"}, "127.0.0.1:53"},
+		{[]string{"1.1.1.1", "::1"}, "[::1]:53"},
+		{[]string{"127.0.0.2"}, ""},
+		{[]string{"127.0.0.53"}, ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := proxyAddress(listen, tt.servers); got != tt.want {
+			t.Errorf("proxyAddress(%v) = %q, want %q", tt.servers, got, tt.want)
+		}
+	}
+	if !forwardsToItself(listen, DNSProvider{Servers: []string{"::1"}}) || forwardsToItself(listen, DNSProvider{Servers: []string{"9.9.9.9"}}) {
+		t.Error("forwardsToItself does not match proxyAddress")
+	}
+}
+
+func TestSplitRemoveWithoutRoutingBackend(t *testing.T) {
+	useFakeBackend(t)
+
+	results, err := RemoveSplitRules()
+	if err != nil || len(results) != 0 {
+		t.Fatalf("got %+v, %v; want nothing to do", results, err)
+	}
+}
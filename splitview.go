@@ -0,0 +1,45 @@
+//go:build !windows
+
+package main
+
+import "fmt"
+
+// splitRouteLines renders the split DNS rules and what enforces them;
+// proxyErr is the outcome of SplitProxyStatus for routes.
+func splitRouteLines(routes []SplitRoute, proxyErr error) []string {
+	var lines []string
+	for _, route := range routes {
+		lines = append(lines, fmt.Sprintf("%-20s %s %s %s",
+			route.Domain, symbol("→", "->"), infoStyle.Render(route.Provider.Name), helpStyle.Render("("+SplitVia(route, proxyErr)+")")))
+	}
+	return lines
+}
+
+// splitResultLines applies the split DNS rules, if any, and renders the
+// outcome. It also returns whether the local proxy enforces the rules left
+// to it, as SplitProxyStatus does.
+func splitResultLines() ([]string, error) {
+	if len(config.Split) == 0 {
+		return nil, nil
+	}
+
+	results, err := ApplySplitRules()
+	var lines []string
+	for _, result := range results {
+		if result.Err != nil {
+			lines = append(lines, errorStyle.Render("Warning: split DNS on "+result.Interface+": "+result.Err.Error()))
+		} else {
+			lines = append(lines, successStyle.Render("Split DNS set on "+result.Interface))
+		}
+	}
+	if err != nil && len(results) == 0 {
+		lines = append(lines, errorStyle.Render("Warning: split DNS: "+err.Error()))
+	}
+
+	routes, _ := SplitRoutes()
+	proxyErr := SplitProxyStatus(routes)
+	if proxyErr != nil {
+		lines = append(lines, errorStyle.Render("Warning: split DNS rules not enforced: "+proxyErr.Error()))
+	}
+	return lines, proxyErr
+}
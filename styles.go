@@ -0,0 +1,103 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/term"
+)
+
+var (
+	titleStyle         lipgloss.Style
+	headerStyle        lipgloss.Style
+	selectedRowStyle   lipgloss.Style
+	normalRowStyle     lipgloss.Style
+	serverStyle        lipgloss.Style
+	borderStyle        lipgloss.Style
+	helpStyle          lipgloss.Style
+	fastLatencyStyle   lipgloss.Style
+	mediumLatencyStyle lipgloss.Style
+	slowLatencyStyle   lipgloss.Style
+	failedLatencyStyle lipgloss.Style
+
+	boxStyle     lipgloss.Style
+	successStyle lipgloss.Style
+	labelStyle   lipgloss.Style
+	errorStyle   lipgloss.Style
+	warningStyle lipgloss.Style
+	infoStyle    lipgloss.Style
+)
+
+// plainOutput drops colors, box drawing, spinners and graphs, leaving text
+// a screen reader reads sensibly. It is also used when the output is not a
+// terminal.
+var plainOutput bool
+
+func init() {
+	useTheme(builtinThemes["dark"], true)
+}
+
+// useTheme builds the styles of the terminal output from theme. Without
+// color only bold, italics and reverse video remain, which is what NO_COLOR
+// asks for.
+func useTheme(theme Theme, color bool) {
+	fg := func(value string) lipgloss.Style {
+		if !color {
+			return lipgloss.NewStyle()
+		}
+		return lipgloss.NewStyle().Foreground(lipgloss.Color(value))
+	}
+
+	titleStyle = fg(theme.Title).Bold(true)
+	headerStyle = fg(theme.Header).Bold(true)
+	normalRowStyle = fg(theme.Text)
+	serverStyle = fg(theme.Info)
+	borderStyle = fg(theme.Muted)
+	helpStyle = fg(theme.Muted).Italic(true)
+	fastLatencyStyle = fg(theme.Success)
+	mediumLatencyStyle = fg(theme.Warning)
+	slowLatencyStyle = fg(theme.Error)
+	failedLatencyStyle = fg(theme.Muted)
+
+	selectedRowStyle = fg(theme.SelectionText).Bold(true)
+	if color {
+		selectedRowStyle = selectedRowStyle.Background(lipgloss.Color(theme.Selection))
+	} else {
+		selectedRowStyle = selectedRowStyle.Reverse(true)
+	}
+
+	boxStyle = fg(theme.Muted)
+	successStyle = fg(theme.Success)
+	labelStyle = fg(theme.Header).Bold(true)
+	errorStyle = fg(theme.Error)
+	warningStyle = fg(theme.Warning).Bold(true)
+	infoStyle = fg(theme.Info)
+}
+
+// setupOutput applies the configured theme, or name when given, and works
+// out whether to use colors and plain output.
+func setupOutput(name string, plain bool) error {
+	if name == "" {
+		name = config.UI.Theme
+	}
+	plainOutput = plain || config.UI.Plain || !term.IsTerminal(os.Stdout.Fd())
+	color := !plainOutput && os.Getenv("NO_COLOR") == ""
+
+	theme, err := LoadTheme(name)
+	if err != nil {
+		useTheme(builtinThemes["dark"], color)
+		return err
+	}
+	useTheme(theme, color)
+	return nil
+}
+
+// symbol returns fancy, or ascii in plain output.
+func symbol(fancy, ascii string) string {
+	if plainOutput {
+		return ascii
+	}
+	return fancy
+}
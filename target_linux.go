@@ -0,0 +1,111 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const netnsRunDir = "/var/run/netns"
+
+// target is the system whose DNS configuration is managed: the running
+// system, a root directory such as an image rootfs or chroot, an "ip netns"
+// network namespace, or a namespace inside a root directory.
+type target struct {
+	root  string
+	netns string
+}
+
+var activeTarget target
+
+// SetTarget directs every read, write and backup to another root directory
+// and/or network namespace. Empty values select the running system.
+func SetTarget(root, netns string) error {
+	if netns != "" {
+		if strings.ContainsAny(netns, "/\x00") || netns == "." || netns == ".." {
+			return fmt.Errorf("invalid network namespace name %q", netns)
+		}
+		if _, err := os.Stat(filepath.Join(netnsRunDir, netns)); err != nil {
+			return fmt.Errorf("network namespace %q not found (create it with: ip netns add %s)", netns, netns)
+		}
+	}
+
+	if root != "" {
+		abs, err := filepath.Abs(root)
+		if err != nil {
+			return fmt.Errorf("invalid root %q: %w", root, err)
+		}
+		info, err := os.Stat(abs)
+		if err != nil || !info.IsDir() {
+			return fmt.Errorf("root %q is not a directory", root)
+		}
+		root = abs
+	}
+
+	activeTarget = target{root: root, netns: netns}
+	if netns != "" {
+		dialDNS = netnsDialer(filepath.Join(netnsRunDir, netns))
+	}
+	return nil
+}
+
+// isSystem reports whether the running system itself is targeted, which is
+// the only case where services such as resolvconf or systemd-resolved can
+// be driven.
+func (t target) isSystem() bool {
+	return t.root == "" && t.netns == ""
+}
+
+// path maps a system path into the target. "ip netns exec" bind mounts
+// /etc/netns/<name>/resolv.conf over /etc/resolv.conf, so that file stands
+// in for resolv.conf inside a namespace.
+func (t target) path(p string) string {
+	if t.netns != "" && p == resolvConfPath {
+		p = filepath.Join("/etc/netns", t.netns, "resolv.conf")
+	}
+	if t.root != "" {
+		p = filepath.Join(t.root, p)
+	}
+	return p
+}
+
+// systemStateDir keeps backups and snapshots with the target: inside the
+// root directory, and per namespace for network namespaces.
+func systemStateDir() string {
+	dir := activeTarget.path("/var/lib/dns-switcher")
+	if activeTarget.netns != "" {
+		dir = filepath.Join(dir, "netns", activeTarget.netns)
+	}
+	return dir
+}
+
+// userStateDir follows the XDG base directory spec. It is the same for
+// every target.
+func userStateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "state"), nil
+}
+
+// targetName describes the active target for display.
+func targetName() string {
+	var parts []string
+	if activeTarget.root != "" {
+		parts = append(parts, "root "+activeTarget.root)
+	}
+	if activeTarget.netns != "" {
+		parts = append(parts, "netns "+activeTarget.netns)
+	}
+	if len(parts) == 0 {
+		return "system"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,206 @@
+//go:build linux
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useTarget points every flow of the test at a temporary root directory,
+// inside network namespace netns if set, through the resolv.conf backend.
+func useTarget(t *testing.T, netns string) string {
+	t.Helper()
+	root := t.TempDir()
+
+	savedTarget, savedBackend, savedConfig := activeTarget, activeBackend, config
+	t.Cleanup(func() {
+		activeTarget, activeBackend, config = savedTarget, savedBackend, savedConfig
+	})
+
+	config = defaultConfig()
+	if err := SetTarget(root, ""); err != nil {
+		t.Fatal(err)
+	}
+	// SetTarget requires the namespace to exist; only its paths matter here.
+	activeTarget.netns = netns
+	if err := UseBackend("resolv.conf"); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+// targetFiles lists the files under root, relative to it.
+func targetFiles(t *testing.T, root string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestSetTarget(t *testing.T) {
+	saved := activeTarget
+	t.Cleanup(func() { activeTarget = saved })
+
+	root := t.TempDir()
+	t.Chdir(filepath.Dir(root))
+	if err := SetTarget(filepath.Base(root), ""); err != nil {
+		t.Fatal(err)
+	}
+	if activeTarget.root != root {
+		t.Fatalf("root is %q, want the absolute %q", activeTarget.root, root)
+	}
+
+	tests := []struct {
+		target target
+		path   string
+		want   string
+	}{
+		{target{}, resolvConfPath, "/etc/resolv.conf"},
+		{target{root: root}, resolvConfPath, root + "/etc/resolv.conf"},
+		{target{root: root}, resolvedDropInPath, root + resolvedDropInPath},
+		{target{netns: "blue"}, resolvConfPath, "/etc/netns/blue/resolv.conf"},
+		{target{netns: "blue"}, resolvedDropInPath, resolvedDropInPath},
+		{target{root: root, netns: "blue"}, resolvConfPath, root + "/etc/netns/blue/resolv.conf"},
+	}
+	for _, tt := range tests {
+		if got := tt.target.path(tt.path); got != tt.want {
+			t.Errorf("%+v maps %s to %s, want %s", tt.target, tt.path, got, tt.want)
+		}
+	}
+
+	for _, bad := range []struct{ root, netns, want string }{
+		{filepath.Join(root, "missing"), "", "is not a directory"},
+		{"", "a/b", "invalid network namespace name"},
+		{"", "..", "invalid network namespace name"},
+		{"", "dns-switcher-test-missing", "not found"},
+	} {
+		err := SetTarget(bad.root, bad.netns)
+		if err == nil || !strings.Contains(err.Error(), bad.want) {
+			t.Errorf("SetTarget(%q, %q) = %v, want an error containing %q", bad.root, bad.netns, err, bad.want)
+		}
+	}
+}
+
+func TestTargetApplyRestore(t *testing.T) {
+	for _, netns := range []string{"", "blue"} {
+		t.Run("netns="+netns, func(t *testing.T) {
+			root := useTarget(t, netns)
+			resolvConf := activeTarget.path(resolvConfPath)
+			stateDir := filepath.Join(root, "var/lib/dns-switcher")
+			if netns != "" {
+				stateDir = filepath.Join(stateDir, "netns", netns)
+			}
+
+			// The root has its own resolv.conf; a namespace starts
+			// without one and uses the host's.
+			original := "nameserver 192.168.1.1\n"
+			if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if netns == "" {
+				if err := os.WriteFile(resolvConf, []byte(original), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if _, err := ApplyProvider(cloudflare); err != nil {
+				t.Fatal(err)
+			}
+			if servers, err := currentBackend().Current(); err != nil || !reflect.DeepEqual(servers, cloudflare.Servers) {
+				t.Fatalf("servers are %v (%v), want %v", servers, err, cloudflare.Servers)
+			}
+
+			b, err := FindBackup("latest")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if filepath.Dir(b.path) != filepath.Join(stateDir, "backups") || b.Target != resolvConf {
+				t.Fatalf("backup of %s is at %s, want a backup of %s in %s", b.Target, b.path, resolvConf, stateDir)
+			}
+			if err := RestoreBackup(b); err != nil {
+				t.Fatal(err)
+			}
+
+			content, err := os.ReadFile(resolvConf)
+			switch {
+			case netns == "" && string(content) != original:
+				t.Errorf("restored %s is %q (%v), want %q", resolvConf, content, err, original)
+			case netns != "" && !os.IsNotExist(err):
+				t.Errorf("restored %s exists (%v), want it removed", resolvConf, err)
+			}
+
+			if filepath.Dir(lockPath()) != stateDir {
+				t.Errorf("lock is %s, want it in %s", lockPath(), stateDir)
+			}
+			for _, file := range targetFiles(t, root) {
+				path := filepath.Join(root, file)
+				if path != resolvConf && !strings.HasPrefix(path, stateDir+"/") {
+					t.Errorf("%s was written outside resolv.conf and %s", path, stateDir)
+				}
+			}
+		})
+	}
+}
+
+func TestLegacyBackups(t *testing.T) {
+	root := useTarget(t, "")
+	legacy := filepath.Join(root, "etc/resolv.conf.bak.20200102_030405")
+	if err := os.MkdirAll(filepath.Dir(legacy), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(legacy, []byte("nameserver 192.168.1.1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, provider := range []DNSProvider{cloudflare, quad9} {
+		if _, err := ApplyProvider(provider); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The copy an older version left in the root is listed for the
+	// resolv.conf backend.
+	backups, err := ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 || backups[2].path != legacy {
+		t.Fatalf("got %d backups, want 2 and the legacy %s last", len(backups), legacy)
+	}
+
+	// Pruning keeps the newest own backup and never deletes the legacy one.
+	removed, err := PruneBackups(BackupPolicy{Keep: 1, MaxAgeDays: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].path == legacy {
+		t.Fatalf("removed %+v, want only the older own backup", removed)
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		t.Fatalf("legacy backup was removed: %v", err)
+	}
+
+	// Other backends cannot restore it, so they do not list it.
+	useFakeBackend(t)
+	backups, err = ListBackups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 0 {
+		t.Fatalf("the fake backend lists %+v, want no backups", backups)
+	}
+}
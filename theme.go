@@ -9,28 +9,98 @@ import (
 	"fyne.io/fyne/v2/theme"
 )
 
+// The colors of the GUI, set from the configured theme by newDNSTheme or
+// to the GUI's own palette by defaultDNSTheme. The ones a theme does not
+// name are mixed from those it does.
 var (
-	colorBackground    = color.NRGBA{R: 27, G: 27, B: 47, A: 255}
-	colorSurface       = color.NRGBA{R: 45, G: 45, B: 68, A: 255}
-	colorSurfaceLight  = color.NRGBA{R: 55, G: 55, B: 82, A: 255}
-	colorPrimary       = color.NRGBA{R: 91, G: 134, B: 229, A: 255}
-	colorPrimaryDark   = color.NRGBA{R: 66, G: 103, B: 190, A: 255}
-	colorSuccess       = color.NRGBA{R: 76, G: 175, B: 80, A: 255}
-	colorWarning       = color.NRGBA{R: 255, G: 193, B: 7, A: 255}
-	colorError         = color.NRGBA{R: 255, G: 82, B: 82, A: 255}
-	colorTextPrimary   = color.NRGBA{R: 224, G: 224, B: 224, A: 255}
-	colorTextSecondary = color.NRGBA{R: 158, G: 158, B: 158, A: 255}
-	colorDivider       = color.NRGBA{R: 60, G: 60, B: 90, A: 255}
-	colorSidebarBg     = color.NRGBA{R: 20, G: 20, B: 38, A: 255}
-	colorCardHover     = color.NRGBA{R: 65, G: 65, B: 100, A: 255}
-	colorConnected     = color.NRGBA{R: 76, G: 175, B: 80, A: 255}
-	colorDisconnected  = color.NRGBA{R: 158, G: 158, B: 158, A: 255}
+	colorBackground    color.NRGBA
+	colorSurface       color.NRGBA
+	colorSurfaceLight  color.NRGBA
+	colorPrimary       color.NRGBA
+	colorPrimaryDark   color.NRGBA
+	colorSuccess       color.NRGBA
+	colorWarning       color.NRGBA
+	colorError         color.NRGBA
+	colorTextPrimary   color.NRGBA
+	colorTextSecondary color.NRGBA
+	colorDivider       color.NRGBA
+	colorSidebarBg     color.NRGBA
+	colorCardHover     color.NRGBA
+	colorConnected     color.NRGBA
+	colorDisconnected  color.NRGBA
 )
 
-type dnsTheme struct{}
+type dnsTheme struct {
+	// variant is the Fyne theme that fills in the colors this one leaves
+	// out, light or dark to match the background.
+	variant fyne.ThemeVariant
+}
+
+// newDNSTheme sets the GUI colors from t, which has been validated by
+// LoadTheme, and returns the Fyne theme using them.
+func newDNSTheme(t Theme) fyne.Theme {
+	c := func(value string) color.NRGBA {
+		parsed, _ := parseColor(value)
+		return parsed
+	}
+	black := color.NRGBA{A: 255}
+
+	colorBackground = c(t.Background)
+	colorSurface = c(t.Surface)
+	colorPrimary = c(t.Primary)
+	colorSuccess = c(t.Success)
+	colorWarning = c(t.Warning)
+	colorError = c(t.Error)
+	colorTextPrimary = c(t.Text)
+	colorTextSecondary = c(t.Muted)
+
+	colorSurfaceLight = mixColor(colorSurface, colorTextPrimary, 0.06)
+	colorPrimaryDark = mixColor(colorPrimary, black, 0.2)
+	colorDivider = mixColor(colorSurface, colorTextPrimary, 0.1)
+	colorSidebarBg = mixColor(colorBackground, black, 0.25)
+	colorCardHover = mixColor(colorSurface, colorPrimary, 0.2)
+	colorConnected = colorSuccess
+	colorDisconnected = colorTextSecondary
+
+	variant := theme.VariantDark
+	if luminance(colorBackground) > 0.5 {
+		variant = theme.VariantLight
+	}
+	return &dnsTheme{variant: variant}
+}
+
+// defaultDNSTheme returns the GUI's own palette, used when no theme is
+// configured.
+func defaultDNSTheme() fyne.Theme {
+	colorBackground = color.NRGBA{R: 27, G: 27, B: 47, A: 255}
+	colorSurface = color.NRGBA{R: 45, G: 45, B: 68, A: 255}
+	colorSurfaceLight = color.NRGBA{R: 55, G: 55, B: 82, A: 255}
+	colorPrimary = color.NRGBA{R: 91, G: 134, B: 229, A: 255}
+	colorPrimaryDark = color.NRGBA{R: 66, G: 103, B: 190, A: 255}
+	colorSuccess = color.NRGBA{R: 76, G: 175, B: 80, A: 255}
+	colorWarning = color.NRGBA{R: 255, G: 193, B: 7, A: 255}
+	colorError = color.NRGBA{R: 255, G: 82, B: 82, A: 255}
+	colorTextPrimary = color.NRGBA{R: 224, G: 224, B: 224, A: 255}
+	colorTextSecondary = color.NRGBA{R: 158, G: 158, B: 158, A: 255}
+	colorDivider = color.NRGBA{R: 60, G: 60, B: 90, A: 255}
+	colorSidebarBg = color.NRGBA{R: 20, G: 20, B: 38, A: 255}
+	colorCardHover = color.NRGBA{R: 65, G: 65, B: 100, A: 255}
+	colorConnected = colorSuccess
+	colorDisconnected = colorTextSecondary
+	return &dnsTheme{variant: theme.VariantDark}
+}
+
+// mixColor blends a with the fraction amount of b.
+func mixColor(a, b color.NRGBA, amount float64) color.NRGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x)*(1-amount) + float64(y)*amount + 0.5)
+	}
+	return color.NRGBA{R: mix(a.R, b.R), G: mix(a.G, b.G), B: mix(a.B, b.B), A: 255}
+}
 
-func newDNSTheme() fyne.Theme {
-	return &dnsTheme{}
+// luminance is the perceived brightness of c from 0 to 1.
+func luminance(c color.NRGBA) float64 {
+	return (0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)) / 255
 }
 
 func (t *dnsTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
@@ -72,7 +142,7 @@ func (t *dnsTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) co
 	case theme.ColorNameScrollBar:
 		return colorDivider
 	default:
-		return theme.DefaultTheme().Color(name, theme.VariantDark)
+		return theme.DefaultTheme().Color(name, t.variant)
 	}
 }
 
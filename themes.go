@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Theme is the palette of the terminal UI and the GUI. Colors are
+// "#RRGGBB". The terminal UI draws on the terminal's own background, so it
+// only uses the text colors; Background and Surface are for the GUI.
+type Theme struct {
+	Background string `json:"background"`
+	Surface    string `json:"surface"`
+	// Text is regular text, Muted help, borders and disabled items.
+	Text  string `json:"text"`
+	Muted string `json:"muted"`
+	// Primary marks buttons and the active item of the GUI.
+	Primary string `json:"primary"`
+	Title   string `json:"title"`
+	Header  string `json:"header"`
+	// Info is used for servers and informational messages.
+	Info string `json:"info"`
+	// Selection and SelectionText draw the selected row.
+	Selection     string `json:"selection"`
+	SelectionText string `json:"selection_text"`
+	// Success, Warning and Error also grade latencies as fast, medium and
+	// slow.
+	Success string `json:"success"`
+	Warning string `json:"warning"`
+	Error   string `json:"error"`
+}
+
+// builtinThemes are the themes that can be picked by name. A user-defined
+// theme starts from one of them.
+var builtinThemes = map[string]Theme{
+	"dark": {
+		Background:    "#1B1B2F",
+		Surface:       "#2D2D44",
+		Text:          "#F8F8F2",
+		Muted:         "#6272A4",
+		Primary:       "#5B86E5",
+		Title:         "#FF79C6",
+		Header:        "#BD93F9",
+		Info:          "#8BE9FD",
+		Selection:     "#44475A",
+		SelectionText: "#50FA7B",
+		Success:       "#50FA7B",
+		Warning:       "#F1FA8C",
+		Error:         "#FF5555",
+	},
+	"light": {
+		Background:    "#F7F7FA",
+		Surface:       "#FFFFFF",
+		Text:          "#1F1F28",
+		Muted:         "#6B6F80",
+		Primary:       "#3461C1",
+		Title:         "#B4237A",
+		Header:        "#6A3FB5",
+		Info:          "#0B6E85",
+		Selection:     "#DCE3F5",
+		SelectionText: "#1F1F28",
+		Success:       "#2B8A3E",
+		Warning:       "#9C6500",
+		Error:         "#C92A2A",
+	},
+	"high-contrast": {
+		Background:    "#000000",
+		Surface:       "#1A1A1A",
+		Text:          "#FFFFFF",
+		Muted:         "#D0D0D0",
+		Primary:       "#FFD700",
+		Title:         "#FFFF00",
+		Header:        "#FFFFFF",
+		Info:          "#00FFFF",
+		Selection:     "#FFFF00",
+		SelectionText: "#000000",
+		Success:       "#00FF00",
+		Warning:       "#FFFF00",
+		Error:         "#FF6060",
+	},
+}
+
+// ThemeNames lists the built-in and configured themes.
+func ThemeNames() []string {
+	var names []string
+	for name := range builtinThemes {
+		names = append(names, name)
+	}
+	for name := range config.UI.Themes {
+		if _, builtin := builtinThemes[name]; !builtin {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadTheme looks up a theme by name: a built-in one or one defined in the
+// "themes" section of the config. A user-defined theme names the theme it
+// starts from in "base", dark if empty, and overrides any of its colors.
+func LoadTheme(name string) (Theme, error) {
+	if name == "" {
+		name = "dark"
+	}
+
+	raw, custom := config.UI.Themes[name]
+	if !custom {
+		theme, ok := builtinThemes[name]
+		if !ok {
+			return Theme{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(ThemeNames(), ", "))
+		}
+		return theme, nil
+	}
+
+	var base struct {
+		Base string `json:"base"`
+	}
+	if err := json.Unmarshal(raw, &base); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+	if base.Base == "" {
+		base.Base = "dark"
+	}
+	theme, ok := builtinThemes[base.Base]
+	if !ok {
+		return Theme{}, fmt.Errorf("theme %s: unknown base theme %q", name, base.Base)
+	}
+
+	if err := json.Unmarshal(raw, &theme); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+	if err := theme.validate(); err != nil {
+		return Theme{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+	return theme, nil
+}
+
+func (t Theme) validate() error {
+	for field, value := range map[string]string{
+		"background": t.Background, "surface": t.Surface, "text": t.Text,
+		"muted": t.Muted, "primary": t.Primary, "title": t.Title,
+		"header": t.Header, "info": t.Info, "selection": t.Selection,
+		"selection_text": t.SelectionText, "success": t.Success,
+		"warning": t.Warning, "error": t.Error,
+	} {
+		if _, err := parseColor(value); err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// parseColor parses a "#RRGGBB" color.
+func parseColor(value string) (color.NRGBA, error) {
+	hex, ok := strings.CutPrefix(value, "#")
+	if !ok || len(hex) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, want #RRGGBB", value)
+	}
+	rgb, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q, want #RRGGBB", value)
+	}
+	return color.NRGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
@@ -13,7 +13,7 @@ import (
 )
 
 func RunApp() {
-	p := tea.NewProgram(initialModel())
+	p := tea.NewProgram(initialModel(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
 		os.Exit(1)
@@ -22,97 +22,288 @@ func RunApp() {
 
 type tickMsg time.Time
 
-var (
-	titleStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF79C6")).
-			Bold(true)
-
-	headerStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#BD93F9")).
-			Bold(true)
-
-	selectedRowStyle = lipgloss.NewStyle().
-				Background(lipgloss.Color("#44475A")).
-				Foreground(lipgloss.Color("#50FA7B")).
-				Bold(true)
-
-	normalRowStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#F8F8F2"))
-
-	serverStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#8BE9FD"))
-
-	borderStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#6272A4"))
-
-	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#6272A4")).
-			Italic(true)
-
-	fastLatencyStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#50FA7B"))
+// latencyMsg carries the result of testing a provider of the list.
+type latencyMsg struct {
+	name    string
+	latency int
+}
 
-	mediumLatencyStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#F1FA8C"))
+// probeMsg carries the result of a latency probe run by probeCmd.
+type probeMsg struct {
+	server  string
+	latency int
+}
 
-	slowLatencyStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#FF5555"))
+// driftMsg carries the result of a drift check run by driftCmd.
+type driftMsg struct {
+	intended []string
+	actual   []string
+	drifted  bool
+	err      error
+}
 
-	failedLatencyStyle = lipgloss.NewStyle().
-				Foreground(lipgloss.Color("#6272A4"))
-)
+// reapplyMsg carries the result of a re-apply run by reapplyCmd.
+type reapplyMsg struct {
+	provider DNSProvider
+	err      error
+}
 
 type model struct {
-	cursor       int
-	selected     int
-	quitting     bool
-	inputMode    bool
-	customInput  string
-	customError  string
-	monitorMode  bool
-	monitorStats MonitorStats
-	scrollOffset int
-	termHeight   int
+	screen         screen
+	cursor         int
+	quitting       bool
+	inputMode      bool
+	customInput    string
+	customError    string
+	pending        DNSProvider
+	plan           ApplyPlan
+	planned        bool
+	planError      string
+	progress       []string
+	newDNS         []string
+	splitErr       error
+	applyError     string
+	spinner        int
+	spinning       bool
+	ticking        bool
+	testing        map[string]bool
+	sortOrder      string
+	detailName     string
+	detailStatus   string
+	editing        string
+	tested         map[string]time.Time
+	history        map[string]LatencyHistory
+	serverLatency  map[string]int
+	serverTesting  map[string]bool
+	searchMode     bool
+	query          string
+	category       string
+	monitorStats   MonitorStats
+	scrollOffset   int
+	termHeight     int
+	termWidth      int
+	backupMode     bool
+	backups        []Backup
+	backupCursor   int
+	backupStatus   string
+	backupFailed   bool
+	backupConfirm  string
+	backupBusy     bool
+	ifaceMode      bool
+	ifaces         []Interface
+	ifaceCursor    int
+	ifaceChecked   map[string]bool
+	ifaceStatus    string
+	lookupDomain   string
+	lookupType     int
+	lookupEditing  bool
+	lookupError    string
+	lookupID       int
+	lookupResults  []LookupResult
+	lookupPending  int
+	lookupMajority bool
+	lookupOffset   int
 }
 
+// MonitorStats holds the monitor dashboard. Provider is what we set;
+// CurrentDNS is what is actually in use, which differs once something else
+// changed DNS and Drifted is set. Checking is set while a drift check runs
+// in the background and Reapplying while the provider is being set again.
+// Probing is set while a latency probe runs in the background; its results
+// go into History. SplitErr says why the local proxy does not enforce the
+// split rules left to it, as checked when the provider was applied.
 type MonitorStats struct {
 	ProviderName   string
+	Provider       DNSProvider
 	CurrentDNS     []string
 	QueriesSuccess int
 	QueriesFailed  int
 	LastLatency    int
 	Uptime         int
+	Drifted        bool
+	AutoReapply    bool
+	Reapplied      int
+	Reapplying     bool
+	DriftError     string
+	Checking       bool
+	Split          []SplitRoute
+	SplitErr       error
+	Probing        bool
+	History        LatencyHistory
 }
 
 func initialModel() model {
-	return model{
-		cursor:       0,
-		selected:     -1,
-		quitting:     false,
-		inputMode:    false,
-		customInput:  "",
-		customError:  "",
-		monitorMode:  false,
-		monitorStats: MonitorStats{},
+	m := model{
+		screen:        screenSelect,
+		cursor:        0,
+		quitting:      false,
+		inputMode:     false,
+		customInput:   "",
+		customError:   "",
+		monitorStats:  MonitorStats{},
+		testing:       map[string]bool{},
+		tested:        map[string]time.Time{},
+		history:       map[string]LatencyHistory{},
+		serverLatency: map[string]int{},
+		serverTesting: map[string]bool{},
+	}
+
+	// Init starts the tests; they are marked here since Init cannot
+	// change the model.
+	for _, provider := range providers {
+		if testable(provider) {
+			m.testing[provider.Name] = true
+		}
+	}
+	m.spinning = len(m.testing) > 0
+	return m
+}
+
+// latencyLabel renders a latency of the list, -1 being a failed test.
+func latencyLabel(latency int) (string, lipgloss.Style) {
+	switch {
+	case latency == -1:
+		return "N/A", failedLatencyStyle
+	case latency < 20:
+		return fmt.Sprintf("%dms", latency), fastLatencyStyle
+	case latency < 50:
+		return fmt.Sprintf("%dms", latency), mediumLatencyStyle
+	default:
+		return fmt.Sprintf("%dms", latency), slowLatencyStyle
+	}
+}
+
+// latencyCmd tests the latency of a provider of the list off the event
+// loop.
+func latencyCmd(provider DNSProvider) tea.Cmd {
+	return func() tea.Msg {
+		return latencyMsg{name: provider.Name, latency: TestDNSLatency(provider.Servers[0])}
+	}
+}
+
+// testProviders re-tests the given providers, showing a spinner in their
+// rows until the results come in.
+func (m model) testProviders(list []DNSProvider) (model, tea.Cmd) {
+	var cmds []tea.Cmd
+	for _, provider := range list {
+		if testable(provider) && !m.testing[provider.Name] {
+			m.testing[provider.Name] = true
+			cmds = append(cmds, latencyCmd(provider))
+		}
+	}
+
+	var spin tea.Cmd
+	m, spin = m.spin()
+	return m, tea.Batch(append(cmds, spin)...)
+}
+
+// sortProviders puts the list in the chosen order, keeping the cursor on
+// the provider it was on.
+func (m model) sortProviders() model {
+	current, _ := m.current()
+	switch m.sortOrder {
+	case "latency":
+		SortProvidersByLatency()
+	case "name":
+		SortProvidersByName()
+	default:
+		PinFavorites()
+	}
+	return m.moveCursorTo(current.Name)
+}
+
+// toggleFavorite stars or unstars provider, saving it to the user state,
+// and pins the favorites to the top again.
+func (m model) toggleFavorite(provider DNSProvider) (model, error) {
+	if err := SetFavorite(provider.Name, !IsFavorite(provider.Name)); err != nil {
+		return m, err
+	}
+	return m.sortProviders(), nil
+}
+
+// rows returns the indexes into providers of the rows the list shows: the
+// providers matching the search and the category filter, in list order.
+// The cursor and scroll offset count these rows.
+func (m model) rows() []int {
+	var rows []int
+	for i, provider := range providers {
+		if m.category != "" && !provider.InCategory(m.category) {
+			continue
+		}
+		if !provider.MatchesQuery(m.query) {
+			continue
+		}
+		rows = append(rows, i)
+	}
+	return rows
+}
+
+// current returns the provider under the cursor, if any row is shown.
+func (m model) current() (DNSProvider, bool) {
+	rows := m.rows()
+	if m.cursor < 0 || m.cursor >= len(rows) {
+		return DNSProvider{}, false
+	}
+	return providers[rows[m.cursor]], true
+}
+
+// moveCursorTo puts the cursor on the provider called name, or on the
+// first row if it is filtered out.
+func (m model) moveCursorTo(name string) model {
+	m.cursor = 0
+	for i, row := range m.rows() {
+		if providers[row].Name == name {
+			m.cursor = i
+			break
+		}
 	}
+	return m.adjustScroll()
+}
+
+// filter changes the search or category and keeps the cursor on the same
+// provider while it still matches.
+func (m model) filter(query, category string) model {
+	current, _ := m.current()
+	m.query = query
+	m.category = category
+	return m.moveCursorTo(current.Name)
 }
 
+// visibleRows is how many providers fit on the screen between the header,
+// measured as tableTop does, and the lines below the table: its bottom
+// border, the scroll indicator, a blank line and the help.
 func (m model) visibleRows() int {
+	total := len(m.rows())
 	if m.termHeight <= 0 {
-		return len(providers)
+		return total
 	}
-	rows := m.termHeight - 12
+	below := 2 + len(m.selectHelp())
+	if !plainOutput {
+		below++
+	}
+	rows := m.termHeight - m.tableTop() - below
 	if rows < 5 {
 		rows = 5
 	}
-	if rows > len(providers) {
-		rows = len(providers)
+	if rows > total {
+		rows = total
 	}
 	return rows
 }
 
 func (m model) adjustScroll() model {
+	total := len(m.rows())
+	if m.cursor >= total {
+		m.cursor = total - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+
 	visible := m.visibleRows()
+	if m.scrollOffset > total-visible {
+		m.scrollOffset = total - visible
+	}
 	if m.cursor < m.scrollOffset {
 		m.scrollOffset = m.cursor
 	}
@@ -131,61 +322,257 @@ func doTick() tea.Cmd {
 	})
 }
 
+// probeCmd measures the latency of server off the event loop.
+func probeCmd(server string) tea.Cmd {
+	return func() tea.Msg {
+		return probeMsg{server: server, latency: TestDNSLatency(server)}
+	}
+}
+
+// probe starts a latency probe of the server in use, unless one is still
+// running.
+func (m model) probe() (model, tea.Cmd) {
+	if m.monitorStats.Probing || len(m.monitorStats.CurrentDNS) == 0 {
+		return m, nil
+	}
+	m.monitorStats.Probing = true
+	return m, probeCmd(m.monitorStats.CurrentDNS[0])
+}
+
 func (m model) Init() tea.Cmd {
-	if m.monitorMode {
-		return doTick()
+	var cmds []tea.Cmd
+	for _, provider := range providers {
+		if m.testing[provider.Name] {
+			cmds = append(cmds, latencyCmd(provider))
+		}
+	}
+	if m.spinning {
+		cmds = append(cmds, spinnerTick())
+	}
+	return tea.Batch(cmds...)
+}
+
+// driftCmd compares the servers in use with intended off the event loop,
+// since backends may have to run a program to find them.
+func driftCmd(intended []string) tea.Cmd {
+	return func() tea.Msg {
+		actual, drifted, err := CheckDrift(intended)
+		return driftMsg{intended: intended, actual: actual, drifted: drifted, err: err}
+	}
+}
+
+// reapplyCmd switches back to provider off the event loop, since it may
+// wait for the lock and runs the cache flushers.
+func reapplyCmd(provider DNSProvider) tea.Cmd {
+	return func() tea.Msg {
+		return reapplyMsg{provider: provider, err: ReapplyProvider(provider)}
 	}
-	return nil
+}
+
+// checkDrift starts comparing the servers in use with the provider we set,
+// unless a check or a re-apply is still running. Nothing is checked after
+// a reset, since the system is then free to change its servers.
+func (m model) checkDrift() (model, tea.Cmd) {
+	stats := &m.monitorStats
+	if stats.Checking || stats.Reapplying || len(stats.Provider.Servers) == 0 {
+		return m, nil
+	}
+	stats.Checking = true
+	return m, driftCmd(stats.Provider.Servers)
+}
+
+// driftChecked records the result of a drift check and re-applies the
+// provider when that is enabled.
+func (m model) driftChecked(msg driftMsg) (model, tea.Cmd) {
+	stats := &m.monitorStats
+	stats.Checking = false
+	// A check of a provider that is no longer monitored says nothing.
+	if !sameServers(msg.intended, stats.Provider.Servers) {
+		return m, nil
+	}
+	if msg.err != nil {
+		return m, nil
+	}
+	stats.Drifted = msg.drifted
+	stats.DriftError = ""
+	if !msg.drifted {
+		stats.CurrentDNS = stats.Provider.Servers
+		return m, nil
+	}
+	stats.CurrentDNS = msg.actual
+
+	if stats.AutoReapply {
+		return m.reapply()
+	}
+	return m, nil
+}
+
+// reapply starts switching back to the provider we set, unless that is
+// already under way.
+func (m model) reapply() (model, tea.Cmd) {
+	if m.monitorStats.Reapplying {
+		return m, nil
+	}
+	m.monitorStats.Reapplying = true
+	return m, reapplyCmd(m.monitorStats.Provider)
+}
+
+func (m model) reapplied(msg reapplyMsg) model {
+	stats := &m.monitorStats
+	if !sameServers(msg.provider.Servers, stats.Provider.Servers) {
+		return m
+	}
+	stats.Reapplying = false
+	if msg.err != nil {
+		stats.DriftError = msg.err.Error()
+		return m
+	}
+	stats.Reapplied++
+	stats.Drifted = false
+	stats.CurrentDNS = stats.Provider.Servers
+	return m
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tickMsg:
-		if m.monitorMode {
+		if m.screen == screenMonitor {
 			m.monitorStats.Uptime++
 
-			if len(m.monitorStats.CurrentDNS) > 0 {
-				latency := TestDNSLatency(m.monitorStats.CurrentDNS[0])
-				if latency > 0 {
-					m.monitorStats.LastLatency = latency
-					m.monitorStats.QueriesSuccess++
-				} else {
-					m.monitorStats.QueriesFailed++
-				}
+			var drift, probe tea.Cmd
+			if m.monitorStats.Uptime%driftInterval() == 0 {
+				m, drift = m.checkDrift()
 			}
+			m, probe = m.probe()
+			return m, tea.Batch(drift, probe, doTick())
+		}
+		m.ticking = false
+		return m, nil
 
-			return m, doTick()
+	case spinnerMsg:
+		if m.spinnerNeeded() {
+			m.spinner++
+			return m, spinnerTick()
+		}
+		m.spinning = false
+		return m, nil
+
+	case latencyMsg:
+		delete(m.testing, msg.name)
+		for i := range providers {
+			if providers[i].Name == msg.name {
+				providers[i].Latency = msg.latency
+			}
+		}
+		history := m.history[msg.name]
+		history.Add(msg.latency)
+		m.history[msg.name] = history
+		m.tested[msg.name] = time.Now()
+		if m.sortOrder == "latency" {
+			m = m.sortProviders()
+		}
+		return m, nil
+
+	case lookupMsg:
+		return m.lookupAnswered(msg), nil
+
+	case serverLatencyMsg:
+		delete(m.serverTesting, msg.server)
+		m.serverLatency[msg.server] = msg.latency
+		return m, nil
+
+	case planMsg:
+		return m.planReady(msg), nil
+
+	case applyMsg:
+		return m.applied(msg)
+
+	case validateMsg:
+		return m.validated(msg)
+
+	case driftMsg:
+		return m.driftChecked(msg)
+
+	case reapplyMsg:
+		return m.reapplied(msg), nil
+
+	case backupMsg:
+		return m.backupDone(msg), nil
+
+	case probeMsg:
+		stats := &m.monitorStats
+		stats.Probing = false
+		// A probe of a server that is no longer in use says nothing about
+		// the current one.
+		if len(stats.CurrentDNS) == 0 || stats.CurrentDNS[0] != msg.server {
+			return m, nil
+		}
+		stats.History.Add(msg.latency)
+		if msg.latency >= 0 {
+			stats.LastLatency = msg.latency
+			stats.QueriesSuccess++
+		} else {
+			stats.QueriesFailed++
 		}
 		return m, nil
 
 	case tea.WindowSizeMsg:
 		m.termHeight = msg.Height
-		return m, nil
+		m.termWidth = msg.Width
+		return m.adjustScroll(), nil
+
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
 
 	case tea.KeyMsg:
-		if m.monitorMode {
+		switch m.screen {
+		case screenConfirm:
+			return m.updateConfirm(msg)
+		case screenApplying, screenValidating:
+			if msg.String() == "ctrl+c" {
+				m.quitting = true
+				return m, tea.Quit
+			}
+			return m, nil
+		case screenDetail:
+			if !m.inputMode {
+				return m.updateDetail(msg)
+			}
+		case screenLookup:
+			return m.updateLookup(msg)
+		}
+
+		if m.screen == screenMonitor {
 			switch msg.String() {
 			case "ctrl+c", "q":
 				m.quitting = true
 				return m, tea.Quit
 			case "r":
-				if len(m.monitorStats.CurrentDNS) > 0 {
-					latency := TestDNSLatency(m.monitorStats.CurrentDNS[0])
-					if latency > 0 {
-						m.monitorStats.LastLatency = latency
-						m.monitorStats.QueriesSuccess++
-					} else {
-						m.monitorStats.QueriesFailed++
-					}
-				}
+				return m.probe()
 			case "c":
-				m.monitorMode = false
-				m.selected = -1
-				return m, tea.Quit
+				m.screen = screenSelect
+				m.progress = nil
+			case "a":
+				m.monitorStats.AutoReapply = !m.monitorStats.AutoReapply
+				if m.monitorStats.AutoReapply && m.monitorStats.Drifted {
+					return m.reapply()
+				}
+			case "f":
+				if m.monitorStats.Drifted {
+					return m.reapply()
+				}
 			}
 			return m, nil
 		}
 
+		if m.backupMode {
+			return m.updateBackups(msg)
+		}
+
+		if m.ifaceMode {
+			return m.updateInterfaces(msg)
+		}
+
 		if m.inputMode {
 			switch msg.String() {
 			case "ctrl+c":
@@ -194,6 +581,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			case "esc":
 				m.inputMode = false
+				m.editing = ""
 				m.customInput = ""
 				m.customError = ""
 
@@ -204,15 +592,19 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					servers := parseCustomDNS(m.customInput)
 					if len(servers) == 0 {
 						m.customError = "Invalid DNS format. Use: 8.8.8.8,1.1.1.1"
+					} else if err := validateServers(servers); err != nil {
+						m.customError = "Invalid DNS format: " + err.Error()
+					} else if m.editing != "" {
+						return m.editServers(servers)
 					} else {
 						customProvider := DNSProvider{
 							Name:    "Custom DNS",
 							Servers: servers,
 							Latency: -1,
 						}
-						providers = append(providers, customProvider)
-						m.selected = len(providers) - 1
-						return m, tea.Quit
+						m.inputMode = false
+						m.customInput = ""
+						return m.confirm(customProvider)
 					}
 				}
 
@@ -231,8 +623,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
+		if m.searchMode {
+			return m.updateSearch(msg)
+		}
+
 		switch msg.String() {
-		case "ctrl+c", "q", "esc":
+		case "esc":
+			if m.query != "" || m.category != "" {
+				m = m.filter("", "")
+				break
+			}
+			m.quitting = true
+			return m, tea.Quit
+
+		case "ctrl+c", "q":
 			m.quitting = true
 			return m, tea.Quit
 
@@ -243,19 +647,82 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "down", "j":
-			if m.cursor < len(providers)-1 {
+			if m.cursor < len(m.rows())-1 {
 				m.cursor++
 				m = m.adjustScroll()
 			}
 
+		case "/":
+			m.searchMode = true
+
+		case "0":
+			m = m.filter(m.query, "")
+
+		case "1", "2", "3", "4", "5":
+			// The key of the selected category clears the filter again.
+			category := ""
+			if i := int(msg.String()[0]) - int('1'); i < len(providerCategories) && providerCategories[i] != m.category {
+				category = providerCategories[i]
+			}
+			m = m.filter(m.query, category)
+
+		case "b":
+			m.backupMode = true
+			m.backupCursor = 0
+			m.backupStatus = ""
+			m = m.loadBackups()
+
+		case "n":
+			m.ifaceMode = true
+			m.ifaceCursor = 0
+			m = m.loadInterfaces()
+
+		case "r":
+			if current, ok := m.current(); ok {
+				return m.testProviders([]DNSProvider{current})
+			}
+
+		case "R":
+			return m.testProviders(providers)
+
+		case "s":
+			if m.sortOrder == "latency" {
+				m.sortOrder = "name"
+			} else {
+				m.sortOrder = "latency"
+			}
+			m = m.sortProviders()
+
+		case "tab", "i":
+			if current, ok := m.current(); ok {
+				return m.openDetail(current)
+			}
+
+		case "l":
+			return m.openLookup(), nil
+
+		case "f":
+			current, ok := m.current()
+			if !ok || !testable(current) {
+				break
+			}
+			var err error
+			if m, err = m.toggleFavorite(current); err != nil {
+				m.applyError = "Could not save favorites: " + err.Error()
+				m.progress = nil
+			}
+
 		case "enter", " ":
-			if providers[m.cursor].Name == "Add Custom DNS" {
+			current, ok := m.current()
+			if !ok {
+				break
+			}
+			if current.Name == "Add Custom DNS" {
 				m.inputMode = true
 				m.customInput = ""
 				m.customError = ""
 			} else {
-				m.selected = m.cursor
-				return m, tea.Quit
+				return m.confirm(current)
 			}
 		}
 	}
@@ -263,12 +730,64 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// updateSearch edits the search while it has focus; the list follows every
+// key. Enter keeps the search and returns to the list, esc drops it.
+func (m model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		m.quitting = true
+		return m, tea.Quit
+
+	case tea.KeyEsc:
+		m.searchMode = false
+		m = m.filter("", m.category)
+
+	case tea.KeyEnter:
+		m.searchMode = false
+
+	case tea.KeyUp:
+		if m.cursor > 0 {
+			m.cursor--
+			m = m.adjustScroll()
+		}
+
+	case tea.KeyDown:
+		if m.cursor < len(m.rows())-1 {
+			m.cursor++
+			m = m.adjustScroll()
+		}
+
+	case tea.KeyBackspace:
+		if query := []rune(m.query); len(query) > 0 {
+			m = m.filter(string(query[:len(query)-1]), m.category)
+		}
+
+	case tea.KeyRunes, tea.KeySpace:
+		m = m.filter(m.query+string(msg.Runes), m.category)
+	}
+
+	return m, nil
+}
+
 func (m model) View() string {
 	if m.quitting {
 		return titleStyle.Render("Goodbye!\n")
 	}
 
-	if m.monitorMode {
+	switch m.screen {
+	case screenConfirm:
+		return m.confirmView()
+	case screenApplying, screenValidating:
+		return m.progressView()
+	case screenDetail:
+		if !m.inputMode {
+			return m.detailView()
+		}
+	case screenLookup:
+		return m.lookupView()
+	}
+
+	if m.screen == screenMonitor {
 		var b strings.Builder
 
 		b.WriteString("\n")
@@ -284,11 +803,33 @@ func (m model) View() string {
 		}
 		b.WriteString("\n")
 
-		border := borderStyle.Render("  ┌────────────────────────┬──────────────┐")
-		b.WriteString(border + "\n")
+		if m.monitorStats.Drifted {
+			b.WriteString(errorStyle.Render("  "+symbol("⚠ ", "Warning: ")+"DNS was changed outside dns-switcher; expected "+
+				strings.Join(m.monitorStats.Provider.Servers, ", ")) + "\n")
+			if m.monitorStats.Reapplying {
+				b.WriteString(helpStyle.Render("  Re-applying "+m.monitorStats.ProviderName+"...") + "\n\n")
+			} else {
+				b.WriteString(helpStyle.Render("  f: re-apply "+m.monitorStats.ProviderName) + "\n\n")
+			}
+		}
+		if m.monitorStats.DriftError != "" {
+			b.WriteString(errorStyle.Render("  "+m.monitorStats.DriftError) + "\n\n")
+		}
+
+		if len(m.monitorStats.Split) > 0 {
+			b.WriteString(headerStyle.Render("  Split DNS:") + "\n")
+			for _, line := range splitRouteLines(m.monitorStats.Split, m.monitorStats.SplitErr) {
+				b.WriteString("    " + line + "\n")
+			}
+			b.WriteString("\n")
+		}
+
+		if !plainOutput {
+			b.WriteString(borderStyle.Render("  ┌────────────────────────┬──────────────┐") + "\n")
+		}
 
 		uptimeStr := formatDuration(m.monitorStats.Uptime)
-		b.WriteString(fmt.Sprintf("  │ %-22s │ %-12s │\n",
+		b.WriteString(statRow(
 			headerStyle.Render("Uptime"),
 			infoStyle.Render(uptimeStr)))
 
@@ -307,37 +848,88 @@ func (m model) View() string {
 			latencyStr = "N/A"
 			latencyColor = failedLatencyStyle
 		}
-		b.WriteString(fmt.Sprintf("  │ %-22s │ %-12s │\n",
+		b.WriteString(statRow(
 			headerStyle.Render("Current Latency"),
 			latencyColor.Render(latencyStr)))
 
-		b.WriteString(fmt.Sprintf("  │ %-22s │ %-12s │\n",
+		b.WriteString(statRow(
 			headerStyle.Render("Queries Success"),
 			fastLatencyStyle.Render(fmt.Sprintf("%d", m.monitorStats.QueriesSuccess))))
 
 		if m.monitorStats.QueriesFailed > 0 {
-			b.WriteString(fmt.Sprintf("  │ %-22s │ %-12s │\n",
+			b.WriteString(statRow(
 				headerStyle.Render("Queries Failed"),
 				slowLatencyStyle.Render(fmt.Sprintf("%d", m.monitorStats.QueriesFailed))))
 		} else {
-			b.WriteString(fmt.Sprintf("  │ %-22s │ %-12s │\n",
+			b.WriteString(statRow(
 				headerStyle.Render("Queries Failed"),
 				infoStyle.Render("0")))
 		}
 
-		bottomBorder := borderStyle.Render("  └────────────────────────┴──────────────┘")
-		b.WriteString(bottomBorder + "\n\n")
+		summary := m.monitorStats.History.Summary()
+		rangeStr, p95Str, lossStr := "N/A", "N/A", "N/A"
+		if summary.Count > 0 && summary.Loss < 100 {
+			rangeStr = fmt.Sprintf("%d/%d/%dms", summary.Min, summary.Avg, summary.Max)
+			p95Str = fmt.Sprintf("%dms", summary.P95)
+		}
+		lossColor := infoStyle
+		if summary.Count > 0 {
+			lossStr = fmt.Sprintf("%.1f%%", summary.Loss)
+			if summary.Loss > 0 {
+				lossColor = slowLatencyStyle
+			}
+		}
+		b.WriteString(statRow(
+			headerStyle.Render("Min/Avg/Max"),
+			infoStyle.Render(rangeStr)))
+		b.WriteString(statRow(
+			headerStyle.Render("P95"),
+			infoStyle.Render(p95Str)))
+		b.WriteString(statRow(
+			headerStyle.Render("Loss"),
+			lossColor.Render(lossStr)))
+
+		if !plainOutput {
+			b.WriteString(borderStyle.Render("  └────────────────────────┴──────────────┘") + "\n")
+		}
+		b.WriteString("\n")
+
+		if samples := m.monitorStats.History.Samples(); len(samples) > 0 && !plainOutput {
+			b.WriteString(headerStyle.Render(fmt.Sprintf("  Latency (last %d):", min(len(samples), m.graphWidth()))) + "\n")
+			b.WriteString("  " + serverStyle.Render(sparkline(samples, m.graphWidth())) + "\n\n")
+		}
 
-		b.WriteString(helpStyle.Render("  r: refresh • c: change DNS • q: quit") + "\n")
+		autoReapply := "off"
+		if m.monitorStats.AutoReapply {
+			autoReapply = "on"
+		}
+		if m.monitorStats.Reapplied > 0 {
+			autoReapply += fmt.Sprintf(", re-applied %d×", m.monitorStats.Reapplied)
+		}
+		for _, line := range wrapHelp(m.termWidth, "r: refresh", "a: auto re-apply ("+autoReapply+")", "c: change DNS", "q: quit") {
+			b.WriteString(helpStyle.Render(line) + "\n")
+		}
 
 		return b.String()
 	}
 
+	if m.backupMode {
+		return m.backupsView()
+	}
+
+	if m.ifaceMode {
+		return m.interfacesView()
+	}
+
 	if m.inputMode {
 		var b strings.Builder
 
+		title := "Add Custom DNS"
+		if m.editing != "" {
+			title = "Edit " + m.editing
+		}
 		b.WriteString("\n")
-		b.WriteString(titleStyle.Render("  Add Custom DNS") + "\n\n")
+		b.WriteString(titleStyle.Render("  "+title) + "\n\n")
 
 		b.WriteString(infoStyle.Render("  Enter DNS servers (comma or space separated):") + "\n\n")
 		b.WriteString(fmt.Sprintf("  > %s_\n\n", m.customInput))
@@ -353,120 +945,467 @@ func (m model) View() string {
 	}
 
 	var b strings.Builder
+	b.WriteString(m.selectHeader())
+
+	layout := m.layout()
+	// row joins the cells of a table line, leaving out the servers column
+	// when the terminal is too narrow for it. Plain output separates the
+	// cells with spaces instead of lines.
+	row := func(name, servers, latency string) string {
+		sep := borderStyle.Render(" │ ")
+		left, right := borderStyle.Render("│ "), borderStyle.Render(" │")
+		if plainOutput {
+			sep, left, right = "  ", "", ""
+		}
+		line := "  " + left + name + sep
+		if layout.servers > 0 {
+			line += servers + sep
+		}
+		return line + latency + right + "\n"
+	}
+	border := func(left, mid, right string) string {
+		if plainOutput {
+			return ""
+		}
+		line := "  " + left + strings.Repeat("─", layout.name+2)
+		if layout.servers > 0 {
+			line += mid + strings.Repeat("─", layout.servers+2)
+		}
+		return borderStyle.Render(line+mid+strings.Repeat("─", layout.latency+2)+right) + "\n"
+	}
 
-	b.WriteString("\n")
-	b.WriteString(titleStyle.Render("  DNS Changer") + "\n")
-	b.WriteString(helpStyle.Render("  Press q or ctrl+c to quit") + "\n\n")
-
-	// Column content widths (characters of visible text)
-	const nameWidth = 20
-	const serverWidth = 40
-	const latWidth = 8
-
-	topBorder := fmt.Sprintf("  ┌%s┬%s┬%s┐",
-		strings.Repeat("─", nameWidth+2),
-		strings.Repeat("─", serverWidth+2),
-		strings.Repeat("─", latWidth+2))
-	b.WriteString(borderStyle.Render(topBorder) + "\n")
-
-	hProvider := fmt.Sprintf("%-*s", nameWidth, "Provider")
-	hServers := fmt.Sprintf("%-*s", serverWidth, "DNS Servers")
-	hLatency := fmt.Sprintf("%-*s", latWidth, "Latency")
-	header := fmt.Sprintf("  │ %s │ %s │ %s │",
-		headerStyle.Render(hProvider),
-		headerStyle.Render(hServers),
-		headerStyle.Render(hLatency))
-	b.WriteString(header + "\n")
-
-	sep := fmt.Sprintf("  ├%s┼%s┼%s┤",
-		strings.Repeat("─", nameWidth+2),
-		strings.Repeat("─", serverWidth+2),
-		strings.Repeat("─", latWidth+2))
-	b.WriteString(borderStyle.Render(sep) + "\n")
+	b.WriteString(border("┌", "┬", "┐"))
+	b.WriteString(row(
+		headerStyle.Render(fitCell("Provider", layout.name)),
+		headerStyle.Render(fitCell("DNS Servers", layout.servers)),
+		headerStyle.Render(fitCell("Latency", layout.latency))))
+	b.WriteString(border("├", "┼", "┤"))
 
 	// Viewport scrolling
+	rows := m.rows()
 	visible := m.visibleRows()
 	startIdx := m.scrollOffset
 	endIdx := startIdx + visible
-	if endIdx > len(providers) {
-		endIdx = len(providers)
+	if endIdx > len(rows) {
+		endIdx = len(rows)
+	}
+
+	if len(rows) == 0 {
+		b.WriteString(row(
+			helpStyle.Render(fitCell("  No match", layout.name)),
+			strings.Repeat(" ", layout.servers),
+			strings.Repeat(" ", layout.latency)))
 	}
 
 	for i := startIdx; i < endIdx; i++ {
-		provider := providers[i]
+		provider := providers[rows[i]]
 
 		providerName := provider.Name
+		if IsFavorite(provider.Name) {
+			providerName += symbol(" ★", " *")
+		}
 		if m.cursor == i {
-			providerName = "▸ " + providerName
+			providerName = symbol("▸ ", "> ") + providerName
 		} else {
 			providerName = "  " + providerName
 		}
-		paddedName := fmt.Sprintf("%-*s", nameWidth, providerName)
+		paddedName := fitCell(providerName, layout.name)
+		paddedServers := fitCell(serverCell(provider, layout.servers), layout.servers)
 
-		var servers string
-		if len(provider.Servers) == 0 {
-			servers = ""
-		} else if len(provider.Servers) == 1 {
-			servers = provider.Servers[0]
-		} else {
-			servers = fmt.Sprintf("%-17s %s", provider.Servers[0], provider.Servers[1])
-		}
-		paddedServers := fmt.Sprintf("%-*s", serverWidth, servers)
-
-		var latencyStr string
-		var latStyle lipgloss.Style
-
-		if provider.Latency == -1 {
-			latencyStr = "N/A"
-			latStyle = failedLatencyStyle
-		} else if provider.Latency < 20 {
-			latencyStr = fmt.Sprintf("%dms", provider.Latency)
-			latStyle = fastLatencyStyle
-		} else if provider.Latency < 50 {
-			latencyStr = fmt.Sprintf("%dms", provider.Latency)
-			latStyle = mediumLatencyStyle
-		} else {
-			latencyStr = fmt.Sprintf("%dms", provider.Latency)
-			latStyle = slowLatencyStyle
+		latencyStr, latStyle := latencyLabel(provider.Latency)
+		if m.testing[provider.Name] {
+			latencyStr = m.spinnerFrame()
+			latStyle = serverStyle
 		}
-		paddedLatency := fmt.Sprintf("%*s", latWidth, latencyStr)
+		paddedLatency := fitCellRight(latencyStr, layout.latency)
 
+		nameStyle, serversStyle := normalRowStyle, serverStyle
 		if m.cursor == i {
-			row := fmt.Sprintf("  │ %s │ %s │ %s │",
-				selectedRowStyle.Render(paddedName),
-				selectedRowStyle.Render(paddedServers),
-				selectedRowStyle.Render(paddedLatency))
-			b.WriteString(row + "\n")
-		} else {
-			row := fmt.Sprintf("  │ %s │ %s │ %s │",
-				normalRowStyle.Render(paddedName),
-				serverStyle.Render(paddedServers),
-				latStyle.Render(paddedLatency))
-			b.WriteString(row + "\n")
+			nameStyle, serversStyle, latStyle = selectedRowStyle, selectedRowStyle, selectedRowStyle
 		}
+		b.WriteString(row(
+			nameStyle.Render(paddedName),
+			serversStyle.Render(paddedServers),
+			latStyle.Render(paddedLatency)))
 	}
 
-	btmBorder := fmt.Sprintf("  └%s┴%s┴%s┘",
-		strings.Repeat("─", nameWidth+2),
-		strings.Repeat("─", serverWidth+2),
-		strings.Repeat("─", latWidth+2))
-	b.WriteString(borderStyle.Render(btmBorder) + "\n")
+	b.WriteString(border("└", "┴", "┘"))
 
 	// Scroll indicators
 	var scrollInfo []string
 	if startIdx > 0 {
-		scrollInfo = append(scrollInfo, fmt.Sprintf("▲ %d more above", startIdx))
+		scrollInfo = append(scrollInfo, fmt.Sprintf("%s %d more above", symbol("▲", "^"), startIdx))
 	}
-	if endIdx < len(providers) {
-		scrollInfo = append(scrollInfo, fmt.Sprintf("▼ %d more below", len(providers)-endIdx))
+	if endIdx < len(rows) {
+		scrollInfo = append(scrollInfo, fmt.Sprintf("%s %d more below", symbol("▼", "v"), len(rows)-endIdx))
 	}
 	if len(scrollInfo) > 0 {
 		b.WriteString(helpStyle.Render("  "+strings.Join(scrollInfo, " • ")) + "\n")
 	}
 	b.WriteString("\n")
 
-	help := helpStyle.Render("  Use ↑/↓ or j/k to navigate • enter to select • q to quit")
-	b.WriteString(help + "\n")
+	for _, line := range m.selectHelp() {
+		b.WriteString(helpStyle.Render(line) + "\n")
+	}
+
+	return b.String()
+}
+
+// selectHelp returns the help lines below the provider table.
+func (m model) selectHelp() []string {
+	nextSort := "latency"
+	if m.sortOrder == "latency" {
+		nextSort = "name"
+	}
+	help := wrapHelp(m.termWidth, "Use ↑/↓ or j/k to navigate", "enter to select", "tab/i: details", "l: lookup", "b: backups", "n: interfaces", "q to quit")
+	if m.searchMode {
+		return append(help, wrapHelp(m.termWidth, "type to search names and servers", "enter: keep", "esc: clear")...)
+	}
+	return append(help, wrapHelp(m.termWidth, "r: re-test", "R: re-test all", "f: favorite", "s: sort by "+nextSort, "/: search", "0-5: filter", "esc: clear")...)
+}
+
+// selectHeader renders the lines above the provider table.
+func (m model) selectHeader() string {
+	var b strings.Builder
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("  DNS Changer") + "\n")
+	b.WriteString(helpStyle.Render("  Press q or ctrl+c to quit") + "\n")
+	if selected := SelectedInterfaces(); len(selected) > 0 {
+		b.WriteString(headerStyle.Render("  Interfaces:") + " " + infoStyle.Render(strings.Join(selected, ", ")) + "\n")
+	}
+	b.WriteString("\n")
+
+	// Search and category filter
+	categories := []string{"0 all"}
+	for i, category := range providerCategories {
+		categories = append(categories, fmt.Sprintf("%d %s", i+1, category))
+	}
+	for i := range categories {
+		if (i == 0 && m.category == "") || (i > 0 && providerCategories[i-1] == m.category) {
+			categories[i] = selectedRowStyle.Render(categories[i])
+		} else {
+			categories[i] = helpStyle.Render(categories[i])
+		}
+	}
+	search := helpStyle.Render("/ to search")
+	if m.searchMode {
+		search = infoStyle.Render("/ " + m.query + "_")
+	} else if m.query != "" {
+		search = infoStyle.Render("/ " + m.query)
+	}
+	filters := "  " + search + "   " + strings.Join(categories, " ")
+	if m.termWidth > 0 && cellWidth(filters) > m.termWidth {
+		filters = "  " + search + "\n  " + strings.Join(categories, " ")
+	}
+	b.WriteString(filters + "\n\n")
+
+	if m.applyError != "" {
+		b.WriteString(errorStyle.Render("  "+m.applyError) + "\n")
+		for _, line := range m.progress {
+			b.WriteString("    " + line + "\n")
+		}
+		b.WriteString("\n")
+	}
+
+	return b.String()
+}
+
+func (m model) loadBackups() model {
+	backups, err := ListBackups()
+	if err != nil {
+		m.backupStatus = err.Error()
+		m.backupFailed = true
+	}
+	m.backups = backups
+	if m.backupCursor >= len(m.backups) {
+		m.backupCursor = len(m.backups) - 1
+	}
+	if m.backupCursor < 0 {
+		m.backupCursor = 0
+	}
+	return m
+}
+
+// backupMsg reports the outcome of a restore or prune started from the
+// backups screen.
+type backupMsg struct {
+	status string
+	err    error
+}
+
+// restoreBackupCmd restores b off the event loop.
+func restoreBackupCmd(b Backup) tea.Cmd {
+	return func() tea.Msg {
+		if err := RestoreBackup(b); err != nil {
+			return backupMsg{err: err}
+		}
+		return backupMsg{status: "Restored backup " + b.ID}
+	}
+}
+
+// pruneBackupsCmd prunes the backups by policy off the event loop.
+func pruneBackupsCmd(policy BackupPolicy) tea.Cmd {
+	return func() tea.Msg {
+		removed, err := PruneBackups(policy)
+		if err != nil {
+			return backupMsg{err: err}
+		}
+		return backupMsg{status: fmt.Sprintf("Pruned %d backup(s)", len(removed))}
+	}
+}
+
+// backupDone shows the outcome of a restore or prune and the backups left.
+func (m model) backupDone(msg backupMsg) model {
+	m.backupBusy = false
+	m.backupStatus = msg.status
+	m.backupFailed = msg.err != nil
+	if msg.err != nil {
+		m.backupStatus = msg.err.Error()
+	}
+	return m.loadBackups()
+}
+
+// updateBackups moves through the backups. A restore or prune is asked
+// for first, then runs in the background while further keys are ignored.
+func (m model) updateBackups(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "ctrl+c" {
+		m.quitting = true
+		return m, tea.Quit
+	}
+	if m.backupBusy {
+		return m, nil
+	}
+
+	if m.backupConfirm != "" {
+		action := m.backupConfirm
+		switch msg.String() {
+		case "q":
+			m.quitting = true
+			return m, tea.Quit
+
+		case "esc", "n", "backspace":
+			m.backupConfirm = ""
+
+		case "enter", "y":
+			m.backupConfirm = ""
+			m.backupBusy = true
+			m.backupStatus = ""
+			cmd := pruneBackupsCmd(config.Backups)
+			if action == "restore" {
+				cmd = restoreBackupCmd(m.backups[m.backupCursor])
+			}
+			var spin tea.Cmd
+			m, spin = m.spin()
+			return m, tea.Batch(cmd, spin)
+		}
+		return m, nil
+	}
+
+	switch msg.String() {
+	case "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "backspace":
+		m.backupMode = false
+
+	case "up", "k":
+		if m.backupCursor > 0 {
+			m.backupCursor--
+		}
+
+	case "down", "j":
+		if m.backupCursor < len(m.backups)-1 {
+			m.backupCursor++
+		}
+
+	case "enter":
+		if len(m.backups) > 0 {
+			m.backupConfirm = "restore"
+		}
+
+	case "p":
+		m.backupConfirm = "prune"
+	}
+
+	return m, nil
+}
+
+func (m model) backupsView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("  Backups") + "\n\n")
+
+	if len(m.backups) == 0 {
+		b.WriteString(infoStyle.Render("  No backups found") + "\n\n")
+	}
+
+	for i, backup := range m.backups {
+		provider := backup.Provider
+		if provider == "" {
+			provider = "-"
+		}
+		line := fmt.Sprintf("%-22s  %-11s  %-12s  %s",
+			backup.ID,
+			backup.Created.Format("01-02 15:04"),
+			backup.Backend,
+			provider)
+		if i == m.backupCursor {
+			b.WriteString("  " + selectedRowStyle.Render(symbol("▸ ", "> ")+line) + "\n")
+		} else {
+			b.WriteString("  " + normalRowStyle.Render("  "+line) + "\n")
+		}
+	}
+
+	if len(m.backups) > 0 {
+		selected := m.backups[m.backupCursor]
+		b.WriteString("\n")
+		b.WriteString(headerStyle.Render("  Servers:") + " " +
+			serverStyle.Render(strings.Join(selected.Servers, "  ")) + "\n")
+	}
+	b.WriteString("\n")
+
+	if m.backupStatus != "" {
+		if m.backupFailed {
+			b.WriteString(errorStyle.Render("  "+m.backupStatus) + "\n\n")
+		} else {
+			b.WriteString(successStyle.Render("  "+m.backupStatus) + "\n\n")
+		}
+	}
+
+	switch {
+	case m.backupBusy:
+		b.WriteString("  " + serverStyle.Render(m.spinnerFrame()) + " " + infoStyle.Render("Working...") + "\n\n")
+		b.WriteString(helpStyle.Render("  ctrl+c: quit") + "\n")
+	case m.backupConfirm == "restore":
+		b.WriteString(titleStyle.Render("  Restore backup "+m.backups[m.backupCursor].ID+"?") + "\n\n")
+		b.WriteString(helpStyle.Render("  enter/y: restore • esc/n: back • q: quit") + "\n")
+	case m.backupConfirm == "prune":
+		b.WriteString(titleStyle.Render("  "+prunePrompt(config.Backups)) + "\n\n")
+		b.WriteString(helpStyle.Render("  enter/y: prune • esc/n: back • q: quit") + "\n")
+	default:
+		b.WriteString(helpStyle.Render("  enter: restore • p: prune • esc: back • q: quit") + "\n")
+	}
+
+	return b.String()
+}
+
+// prunePrompt asks whether to prune by policy, saying what would go.
+func prunePrompt(policy BackupPolicy) string {
+	var limits []string
+	if policy.Keep > 0 {
+		limits = append(limits, fmt.Sprintf("beyond the newest %d", policy.Keep))
+	}
+	if policy.MaxAgeDays > 0 {
+		limits = append(limits, fmt.Sprintf("older than %d days", policy.MaxAgeDays))
+	}
+	if len(limits) == 0 {
+		return "Prune backups? The policy keeps them all."
+	}
+	return "Prune backups " + strings.Join(limits, " or ") + "?"
+}
+
+func (m model) loadInterfaces() model {
+	interfaces, err := ListInterfaces()
+	m.ifaces = interfaces
+	m.ifaceStatus = ""
+	if err != nil {
+		m.ifaceStatus = err.Error()
+	}
+
+	m.ifaceChecked = map[string]bool{}
+	for _, iface := range interfaces {
+		m.ifaceChecked[iface.Name] = allInterfacesSelected()
+	}
+	for _, name := range SelectedInterfaces() {
+		m.ifaceChecked[name] = true
+	}
+	return m
+}
+
+func (m model) updateInterfaces(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		m.quitting = true
+		return m, tea.Quit
+
+	case "esc", "backspace":
+		m.ifaceMode = false
+
+	case "up", "k":
+		if m.ifaceCursor > 0 {
+			m.ifaceCursor--
+		}
+
+	case "down", "j":
+		if m.ifaceCursor < len(m.ifaces)-1 {
+			m.ifaceCursor++
+		}
+
+	case " ", "x":
+		if len(m.ifaces) > 0 {
+			name := m.ifaces[m.ifaceCursor].Name
+			m.ifaceChecked[name] = !m.ifaceChecked[name]
+		}
+
+	case "a":
+		all := true
+		for _, iface := range m.ifaces {
+			all = all && m.ifaceChecked[iface.Name]
+		}
+		for _, iface := range m.ifaces {
+			m.ifaceChecked[iface.Name] = !all
+		}
+
+	case "enter":
+		var names []string
+		for _, iface := range m.ifaces {
+			if m.ifaceChecked[iface.Name] {
+				names = append(names, iface.Name)
+			}
+		}
+		if len(names) == len(m.ifaces) && len(names) > 1 {
+			names = []string{"all"}
+		}
+		SelectInterfaces(names)
+		m.ifaceMode = false
+	}
+
+	return m, nil
+}
+
+func (m model) interfacesView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString(titleStyle.Render("  Interfaces") + "\n\n")
+
+	for i, iface := range m.ifaces {
+		check := "[ ]"
+		if m.ifaceChecked[iface.Name] {
+			check = "[x]"
+		}
+		name := iface.Name
+		if iface.Active {
+			name += " *"
+		}
+		line := fmt.Sprintf("%s %-24s  %s", check, name, strings.Join(iface.Servers, "  "))
+		if i == m.ifaceCursor {
+			b.WriteString("  " + selectedRowStyle.Render(symbol("▸ ", "> ")+line) + "\n")
+		} else {
+			b.WriteString("  " + normalRowStyle.Render("  "+line) + "\n")
+		}
+	}
+	b.WriteString("\n")
+
+	if m.ifaceStatus != "" {
+		b.WriteString(errorStyle.Render("  "+m.ifaceStatus) + "\n\n")
+	} else {
+		b.WriteString(helpStyle.Render("  * changed when nothing is selected") + "\n\n")
+	}
+
+	b.WriteString(helpStyle.Render("  space: toggle • a: all • enter: use selection • esc: back • q: quit") + "\n")
 
 	return b.String()
 }
@@ -0,0 +1,285 @@
+//go:build !windows
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func keyMsg(key string) tea.KeyMsg {
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+// monitorModel returns the monitor of provider, which the fake backend
+// already uses.
+func monitorModel(t *testing.T, provider DNSProvider) model {
+	t.Helper()
+	if _, err := ApplyProvider(provider); err != nil {
+		t.Fatal(err)
+	}
+	m := initialModel()
+	m.screen = screenMonitor
+	m.monitorStats = MonitorStats{ProviderName: provider.Name, Provider: provider, CurrentDNS: provider.Servers}
+	return m
+}
+
+func TestMonitorDriftOffEventLoop(t *testing.T) {
+	f := useFakeBackend(t)
+	m := monitorModel(t, cloudflare)
+
+	// Something else changes DNS behind the monitor's back.
+	if err := f.SetInterfaceServers("eth0", quad9.Servers); err != nil {
+		t.Fatal(err)
+	}
+
+	m, cmd := m.checkDrift()
+	if cmd == nil {
+		t.Fatal("no drift check was started")
+	}
+	if m.monitorStats.Drifted {
+		t.Fatal("drift was checked inside Update")
+	}
+
+	next, _ := m.Update(cmd())
+	m = next.(model)
+	if !m.monitorStats.Drifted || !reflect.DeepEqual(m.monitorStats.CurrentDNS, quad9.Servers) {
+		t.Fatalf("drift not recorded: %+v", m.monitorStats)
+	}
+
+	next, cmd = m.Update(keyMsg("f"))
+	m = next.(model)
+	if cmd == nil || !m.monitorStats.Reapplying {
+		t.Fatal("re-apply was not started as a command")
+	}
+	if current, _ := f.Current(); !reflect.DeepEqual(current, quad9.Servers) {
+		t.Fatal("the provider was re-applied inside Update")
+	}
+
+	// A second request while one is under way starts nothing.
+	if _, again := m.Update(keyMsg("f")); again != nil {
+		t.Fatal("a second re-apply was started")
+	}
+
+	next, _ = m.Update(cmd())
+	m = next.(model)
+	if m.monitorStats.Reapplying || m.monitorStats.Drifted || m.monitorStats.Reapplied != 1 {
+		t.Fatalf("re-apply not recorded: %+v", m.monitorStats)
+	}
+	if current, _ := f.Current(); !reflect.DeepEqual(current, cloudflare.Servers) {
+		t.Fatalf("servers are %v after re-applying, want %v", current, cloudflare.Servers)
+	}
+}
+
+func TestMonitorTickDriftCheckInFlight(t *testing.T) {
+	useFakeBackend(t)
+	m := monitorModel(t, cloudflare)
+	m.monitorStats.Uptime = driftInterval() - 1
+
+	next, _ := m.Update(tickMsg{})
+	m = next.(model)
+	if !m.monitorStats.Checking {
+		t.Fatal("the tick did not start a drift check")
+	}
+	check := driftCmd(cloudflare.Servers)
+
+	// The next interval comes before the check returns.
+	if _, cmd := m.checkDrift(); cmd != nil {
+		t.Fatal("a second drift check was started while one is running")
+	}
+
+	next, _ = m.Update(check())
+	m = next.(model)
+	if m.monitorStats.Checking || m.monitorStats.Drifted {
+		t.Fatalf("check not recorded: %+v", m.monitorStats)
+	}
+	if _, cmd := m.checkDrift(); cmd == nil {
+		t.Fatal("no drift check was started after the last one returned")
+	}
+}
+
+func TestMonitorStaleDriftCheck(t *testing.T) {
+	useFakeBackend(t)
+	m := monitorModel(t, cloudflare)
+	m, _ = m.checkDrift()
+
+	// The monitored provider changes before the check returns.
+	m.monitorStats.Provider = quad9
+	next, _ := m.Update(driftMsg{intended: cloudflare.Servers, actual: quad9.Servers, drifted: true})
+	m = next.(model)
+	if m.monitorStats.Checking || m.monitorStats.Drifted {
+		t.Fatalf("stale check not discarded: %+v", m.monitorStats)
+	}
+	if _, cmd := m.checkDrift(); cmd == nil {
+		t.Fatal("no drift check was started after a stale one returned")
+	}
+}
+
+func TestCategoryKeys(t *testing.T) {
+	useFakeBackend(t)
+	m := initialModel()
+
+	steps := []struct {
+		key  string
+		want string
+	}{
+		{"0", ""},
+		{"2", "filtering"},
+		{"2", ""},
+		{"5", "custom"},
+		{"1", "privacy"},
+		{"0", ""},
+		{"0", ""},
+	}
+	for _, step := range steps {
+		next, _ := m.Update(keyMsg(step.key))
+		m = next.(model)
+		if m.category != step.want {
+			t.Fatalf("after %q the category is %q, want %q", step.key, m.category, step.want)
+		}
+	}
+}
+
+func TestSelectViewFitsTerminal(t *testing.T) {
+	useFakeBackend(t)
+
+	tests := []struct {
+		name  string
+		setup func(m model) model
+	}{
+		{"plain header", func(m model) model { return m }},
+		{"apply error", func(m model) model {
+			m.applyError = "Switching to Quad9 failed: permission denied"
+			m.progress = []string{"eth0: permission denied", "wlan0: permission denied"}
+			return m
+		}},
+		{"interfaces", func(m model) model {
+			SelectInterfaces([]string{"eth0"})
+			return m
+		}},
+		{"wrapped filters and help", func(m model) model {
+			m.termWidth = 50
+			m.searchMode = true
+			return m
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { SelectInterfaces(nil) })
+			m := initialModel()
+			m.termWidth, m.termHeight = 120, 30
+			m = tt.setup(m)
+			if len(m.rows()) <= m.visibleRows() {
+				t.Fatalf("all %d providers fit; the test needs more", len(m.rows()))
+			}
+
+			// A full table takes the whole terminal and no more, so the
+			// header stays on screen.
+			m.cursor = len(m.rows()) - 1
+			m = m.adjustScroll()
+			if lines := strings.Count(m.View(), "\n"); lines != m.termHeight {
+				t.Fatalf("view has %d lines on a terminal of %d", lines, m.termHeight)
+			}
+		})
+	}
+}
+
+func TestResizeKeepsCursorVisible(t *testing.T) {
+	m := initialModel()
+	m.termWidth, m.termHeight = 120, 30
+	m.cursor = len(m.rows()) - 1
+	m = m.adjustScroll()
+
+	// Shrinking must scroll the cursor back into view; growing must not
+	// leave blank rows below the last provider.
+	for _, height := range []int{22, 30} {
+		next, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: height})
+		m = next.(model)
+		if m.cursor < m.scrollOffset || m.cursor >= m.scrollOffset+m.visibleRows() {
+			t.Fatalf("at %d lines the cursor %d is outside rows %d-%d", height, m.cursor, m.scrollOffset, m.scrollOffset+m.visibleRows()-1)
+		}
+		if lines := strings.Count(m.View(), "\n"); lines != height {
+			t.Fatalf("view has %d lines on a terminal of %d", lines, height)
+		}
+	}
+}
+
+// backupResult runs the commands cmd batches and returns the outcome of the
+// restore or prune among them.
+func backupResult(t *testing.T, cmd tea.Cmd) backupMsg {
+	t.Helper()
+	cmds := []tea.Cmd{cmd}
+	for len(cmds) > 0 {
+		msg := cmds[0]()
+		cmds = cmds[1:]
+		switch msg := msg.(type) {
+		case tea.BatchMsg:
+			cmds = append(cmds, msg...)
+		case backupMsg:
+			return msg
+		}
+	}
+	t.Fatal("no restore or prune was started")
+	return backupMsg{}
+}
+
+func TestBackupsConfirmedOffEventLoop(t *testing.T) {
+	f := useFakeBackend(t)
+	for _, provider := range []DNSProvider{cloudflare, quad9} {
+		if _, err := ApplyProvider(provider); err != nil {
+			t.Fatal(err)
+		}
+	}
+	press := func(m model, msg tea.Msg) (model, tea.Cmd) {
+		next, cmd := m.Update(msg)
+		return next.(model), cmd
+	}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+
+	m, _ := press(initialModel(), keyMsg("b"))
+	if len(m.backups) != 2 {
+		t.Fatalf("got %d backups, want 2", len(m.backups))
+	}
+
+	// Restoring asks first, and can be called off.
+	m, cmd := press(m, enter)
+	if cmd != nil || m.backupConfirm != "restore" || !strings.Contains(m.View(), "Restore backup "+m.backups[0].ID+"?") {
+		t.Fatal("restore was not asked for")
+	}
+	m, _ = press(m, keyMsg("n"))
+	if m.backupConfirm != "" || !m.backupMode {
+		t.Fatal("n did not call the restore off")
+	}
+
+	m, _ = press(m, enter)
+	m, cmd = press(m, keyMsg("y"))
+	if cmd == nil || !m.backupBusy {
+		t.Fatal("the restore was not started as a command")
+	}
+	checkServers(t, f, map[string][]string{"eth0": quad9.Servers, "wlan0": {"192.168.0.1"}})
+	if m, ignored := press(m, keyMsg("p")); ignored != nil || m.backupConfirm != "" {
+		t.Fatal("a prune was asked for while the restore runs")
+	}
+
+	m, _ = press(m, backupResult(t, cmd))
+	if m.backupBusy || m.backupFailed || !strings.HasPrefix(m.backupStatus, "Restored backup ") {
+		t.Fatalf("restore not reported: %q", m.backupStatus)
+	}
+	checkServers(t, f, map[string][]string{"eth0": cloudflare.Servers, "wlan0": {"192.168.0.1"}})
+
+	// The restore backed up what it replaced, so only that one is kept.
+	config.Backups = BackupPolicy{Keep: 1}
+	m, cmd = press(m, keyMsg("p"))
+	if cmd != nil || !strings.Contains(m.View(), "Prune backups beyond the newest 1?") {
+		t.Fatal("prune was not asked for")
+	}
+	m, cmd = press(m, keyMsg("y"))
+	m, _ = press(m, backupResult(t, cmd))
+	if m.backupStatus != "Pruned 2 backup(s)" || len(m.backups) != 1 {
+		t.Fatalf("got %q with %d backups left, want 2 pruned and 1 left", m.backupStatus, len(m.backups))
+	}
+}